@@ -0,0 +1,129 @@
+// Audit log of privileged bot actions. Entries are appended to a
+// JSON-lines file and, if configured, mirrored to a Discord channel.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+var auditLogPath string
+var auditChannelID string
+var auditFileMutex sync.Mutex
+
+// Set up variables, loading from environment where necessary
+func init() {
+	auditLogPath = os.Getenv("AUDIT_LOG_PATH")
+	if auditLogPath == "" {
+		auditLogPath = "audit.jsonl"
+	}
+	auditChannelID = os.Getenv("AUDIT_CHANNEL_ID")
+}
+
+// A single privileged action taken through the bot.
+type Entry struct {
+	Time       time.Time         `json:"time"`
+	UserID     string            `json:"user_id"`
+	Username   string            `json:"username"`
+	Action     string            `json:"action"`
+	Args       map[string]string `json:"args,omitempty"`
+	Outcome    string            `json:"outcome"`
+	Detail     string            `json:"detail,omitempty"`
+	DurationMs int64             `json:"duration_ms"`
+}
+
+func (e Entry) String() string {
+	s := fmt.Sprintf("%v %v ran %v", e.Time.Format(time.RFC3339), e.Username, e.Action)
+	keys := make([]string, 0, len(e.Args))
+	for k := range e.Args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s += fmt.Sprintf(" %v=%v", k, e.Args[k])
+	}
+	s += fmt.Sprintf(": %v in %v", e.Outcome, time.Duration(e.DurationMs)*time.Millisecond)
+	if e.Detail != "" {
+		s += fmt.Sprintf(" (%v)", e.Detail)
+	}
+	return s
+}
+
+// Appends an entry to the audit log file and mirrors it to the audit
+// channel. Failures are logged rather than returned so that auditing never
+// gets in the way of the action itself.
+func Record(s *discordgo.Session, e Entry) {
+	err := appendEntry(e)
+	if err != nil {
+		log.WithError(err).Errorf("unable to write audit entry: %v", e)
+	}
+
+	if auditChannelID != "" && s != nil {
+		_, err = s.ChannelMessageSendComplex(auditChannelID, &discordgo.MessageSend{
+			Content:         fmt.Sprintf("`%v`", e),
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		})
+		if err != nil {
+			log.WithError(err).Error("unable to mirror audit entry to channel")
+		}
+	}
+}
+
+func appendEntry(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	auditFileMutex.Lock()
+	defer auditFileMutex.Unlock()
+
+	f, err := os.OpenFile(auditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Returns the last n entries of the audit log, oldest first.
+func Recent(n int) ([]Entry, error) {
+	auditFileMutex.Lock()
+	defer auditFileMutex.Unlock()
+
+	f, err := os.Open(auditLogPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			log.WithError(err).Warn("skipping malformed audit entry")
+			continue
+		}
+		entries = append(entries, e)
+		if len(entries) > n {
+			entries = entries[1:]
+		}
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,110 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const CloudflareAPIURL = "https://api.cloudflare.com/client/v4"
+
+// Cloudflare manages records through the Cloudflare v4 HTTP API (or any
+// service implementing the same endpoints).
+type Cloudflare struct {
+	APIURL     string
+	Token      string
+	ZoneID     string
+	HTTPClient *http.Client
+}
+
+func NewCloudflare(apiURL string, token string, zoneID string) *Cloudflare {
+	if apiURL == "" {
+		apiURL = CloudflareAPIURL
+	}
+	return &Cloudflare{
+		APIURL:     strings.TrimSuffix(apiURL, "/"),
+		Token:      token,
+		ZoneID:     zoneID,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+func (c *Cloudflare) do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&reqBody).Encode(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.APIURL+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var r cloudflareResponse
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err != nil {
+		return fmt.Errorf("cannot decode DNS API response (status %v): %w", resp.Status, err)
+	}
+	if !r.Success {
+		var msgs []string
+		for _, e := range r.Errors {
+			msgs = append(msgs, fmt.Sprintf("%v: %v", e.Code, e.Message))
+		}
+		return fmt.Errorf("DNS API request failed (status %v): %v", resp.Status, strings.Join(msgs, ", "))
+	}
+	if result != nil {
+		return json.Unmarshal(r.Result, result)
+	}
+	return nil
+}
+
+func (c *Cloudflare) UpdateRecord(ctx context.Context, name string, ip string) error {
+	var records []cloudflareRecord
+	query := url.Values{"type": {"A"}, "name": {name}}
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/zones/%v/dns_records?%v", c.ZoneID, query.Encode()), nil, &records)
+	if err != nil {
+		return err
+	}
+
+	record := cloudflareRecord{Type: "A", Name: name, Content: ip, TTL: 60}
+	if len(records) == 0 {
+		return c.do(ctx, http.MethodPost, fmt.Sprintf("/zones/%v/dns_records", c.ZoneID), record, nil)
+	}
+	if records[0].Content == ip {
+		return nil
+	}
+	return c.do(ctx, http.MethodPut, fmt.Sprintf("/zones/%v/dns_records/%v", c.ZoneID, records[0].ID), record, nil)
+}
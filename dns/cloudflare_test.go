@@ -0,0 +1,123 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// A stand-in for the Cloudflare API holding at most one A record.
+type fakeCloudflare struct {
+	t       *testing.T
+	record  *cloudflareRecord
+	methods []string
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.methods = append(f.methods, r.Method)
+	if got := r.Header.Get("Authorization"); got != "Bearer token" {
+		f.t.Errorf("Authorization = %q", got)
+	}
+
+	var result interface{}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/zones/zone/dns_records":
+		if q := r.URL.Query(); q.Get("type") != "A" || q.Get("name") != "mc.example.com" {
+			f.t.Errorf("unexpected query %v", r.URL.RawQuery)
+		}
+		records := []cloudflareRecord{}
+		if f.record != nil {
+			records = append(records, *f.record)
+		}
+		result = records
+	case r.Method == http.MethodPost && r.URL.Path == "/zones/zone/dns_records":
+		var rec cloudflareRecord
+		json.NewDecoder(r.Body).Decode(&rec)
+		rec.ID = "new"
+		f.record = &rec
+	case r.Method == http.MethodPut && f.record != nil && r.URL.Path == "/zones/zone/dns_records/"+f.record.ID:
+		var rec cloudflareRecord
+		json.NewDecoder(r.Body).Decode(&rec)
+		rec.ID = f.record.ID
+		f.record = &rec
+	default:
+		f.t.Errorf("unexpected request %v %v", r.Method, r.URL)
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"errors":  []map[string]interface{}{{"code": 7003, "message": "no route"}},
+		})
+		return
+	}
+	raw, _ := json.Marshal(result)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "result": json.RawMessage(raw)})
+}
+
+func TestCloudflareUpdateRecord(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing *cloudflareRecord
+		wantID   string
+		// Requests made, in order.
+		want []string
+	}{
+		{
+			name:   "create",
+			wantID: "new",
+			want:   []string{http.MethodGet, http.MethodPost},
+		},
+		{
+			name:     "update",
+			existing: &cloudflareRecord{ID: "abc", Type: "A", Name: "mc.example.com", Content: "192.0.2.1", TTL: 60},
+			wantID:   "abc",
+			want:     []string{http.MethodGet, http.MethodPut},
+		},
+		{
+			name:     "no-op",
+			existing: &cloudflareRecord{ID: "abc", Type: "A", Name: "mc.example.com", Content: "192.0.2.2", TTL: 60},
+			wantID:   "abc",
+			want:     []string{http.MethodGet},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := &fakeCloudflare{t: t, record: test.existing}
+			srv := httptest.NewServer(api)
+			defer srv.Close()
+
+			c := NewCloudflare(srv.URL+"/", "token", "zone")
+			err := c.UpdateRecord(context.Background(), "mc.example.com", "192.0.2.2")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(api.methods) != len(test.want) {
+				t.Fatalf("requests = %v, want %v", api.methods, test.want)
+			}
+			for i := range test.want {
+				if api.methods[i] != test.want[i] {
+					t.Errorf("requests = %v, want %v", api.methods, test.want)
+				}
+			}
+			if api.record == nil || api.record.ID != test.wantID || api.record.Content != "192.0.2.2" || api.record.Name != "mc.example.com" {
+				t.Errorf("record = %+v", api.record)
+			}
+		})
+	}
+}
+
+func TestCloudflareError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"success":false,"errors":[{"code":10000,"message":"Authentication error"}]}`))
+	}))
+	defer srv.Close()
+
+	err := NewCloudflare(srv.URL, "token", "zone").UpdateRecord(context.Background(), "mc.example.com", "192.0.2.2")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,61 @@
+// Dynamic DNS: points the MC server's hostname at the instance's current
+// external IP, which changes every time an ephemeral-IP instance starts.
+
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// A DNS provider that can manage A records.
+type Provider interface {
+	// Points the A record for name at ip, creating it if necessary.
+	UpdateRecord(ctx context.Context, name string, ip string) error
+}
+
+// Looks up the addresses a name resolves to. *net.Resolver is one.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+const propagationPollInterval = 5 * time.Second
+
+// Waits until resolver returns ip for name, or ctx expires. A nil
+// resolver uses the system resolver.
+func WaitForPropagation(ctx context.Context, resolver Resolver, name string, ip string) error {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	for {
+		addrs, err := resolver.LookupHost(ctx, name)
+		if err == nil {
+			for _, a := range addrs {
+				if a == ip {
+					return nil
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%v did not resolve to %v in time (last saw %v): %w", name, ip, addrs, ctx.Err())
+		case <-time.After(propagationPollInterval):
+		}
+	}
+}
+
+// Returns a resolver that queries the given DNS server (host:port)
+// directly, bypassing local caches.
+func ResolverFor(server string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
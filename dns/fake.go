@@ -0,0 +1,41 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Fake keeps records in memory, for tests. It is also a Resolver that
+// answers from those records.
+type Fake struct {
+	mutex   sync.Mutex
+	Records map[string]string
+}
+
+func NewFake() *Fake {
+	return &Fake{Records: make(map[string]string)}
+}
+
+func (f *Fake) UpdateRecord(ctx context.Context, name string, ip string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.Records[name] = ip
+	return nil
+}
+
+// Returns the IP name points to, if any.
+func (f *Fake) Lookup(name string) (string, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	ip, ok := f.Records[name]
+	return ip, ok
+}
+
+func (f *Fake) LookupHost(ctx context.Context, host string) ([]string, error) {
+	ip, ok := f.Lookup(host)
+	if !ok {
+		return nil, fmt.Errorf("no such host %v", host)
+	}
+	return []string{ip}, nil
+}
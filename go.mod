@@ -3,11 +3,13 @@ module github.com/mirrorkeydev/discord-mc-bot
 go 1.16
 
 require (
-	github.com/bwmarrin/discordgo v0.23.3-0.20210529215543-f5bb723db8d9
+	github.com/bwmarrin/discordgo v0.24.0
 	github.com/golang/protobuf v1.5.2
+	github.com/prometheus/client_golang v1.11.0
 	github.com/sirupsen/logrus v1.8.1
-	golang.org/x/oauth2 v0.0.0-20210514164344-f6687ab2804c
-	google.golang.org/api v0.48.0
-	google.golang.org/grpc v1.38.0
-	google.golang.org/protobuf v1.26.0
+	go.etcd.io/bbolt v1.3.6
+	golang.org/x/oauth2 v0.0.0-20211104180415-d3ed0bb246c8
+	google.golang.org/api v0.70.0
+	google.golang.org/grpc v1.44.0
+	google.golang.org/protobuf v1.27.1
 )
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	log "github.com/sirupsen/logrus"
+)
+
+// How long before a certificate expires to start warning admins.
+const certWarningDays = 14
+
+func Admin(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	defer metrics.ObserveCommand("admin "+i.ApplicationCommandData().Options[0].Name, time.Now(), outcomeSuccess)
+
+	if !isAdmin(i) {
+		respondEphemeral(s, i, "only admins can do that :no_entry:")
+		return
+	}
+
+	switch i.ApplicationCommandData().Options[0].Name {
+	case "certs":
+		statuses, err := server.CertStatuses()
+		if err != nil {
+			log.WithError(err).Error("unable to read certificates")
+			respondEphemeral(s, i, "unable to read the certificates")
+			return
+		}
+
+		var lines []string
+		for _, c := range statuses {
+			lines = append(lines, fmt.Sprintf("%v: expires %v (%v days left)", c.Name, c.NotAfter.Format("2006-01-02"), c.DaysLeft()))
+		}
+		respondEphemeral(s, i, fmt.Sprintf("```\n%v\n```", strings.Join(lines, "\n")))
+	}
+}
+
+// Checks the certificates twice a day and warns in the admin channel once
+// a day while any of them is close to expiring.
+func WatchCertExpiry(s *discordgo.Session) {
+	if adminChannelID == "" {
+		log.Warn("DISCORD_ADMIN_CHANNEL_ID not set, certificate expiry warnings are disabled")
+		return
+	}
+
+	lastWarned := make(map[string]time.Time)
+	for {
+		warnExpiringCerts(s, lastWarned)
+		time.Sleep(12 * time.Hour)
+	}
+}
+
+func warnExpiringCerts(s *discordgo.Session, lastWarned map[string]time.Time) {
+	statuses, err := server.CertStatuses()
+	if err != nil {
+		log.WithError(err).Error("unable to check certificate expiry")
+		return
+	}
+
+	for _, c := range statuses {
+		if c.DaysLeft() > certWarningDays || time.Since(lastWarned[c.Name]) < 24*time.Hour {
+			continue
+		}
+		_, err := s.ChannelMessageSend(adminChannelID, fmt.Sprintf(":warning: the %v certificate expires in %v days (%v)", c.Name, c.DaysLeft(), c.NotAfter.Format("2006-01-02")))
+		if err != nil {
+			log.WithError(err).Error("unable to send certificate expiry warning")
+			continue
+		}
+		lastWarned[c.Name] = time.Now()
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/audit"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultAuditCount = 10
+const maxAuditCount = 25
+
+func Audit(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	defer metrics.ObserveCommand("audit", time.Now(), outcomeSuccess)
+
+	if !isAdmin(i) {
+		respondEphemeral(s, i, "only admins can read the audit log :no_entry:")
+		return
+	}
+
+	switch i.ApplicationCommandData().Options[0].Name {
+	case "recent":
+		count := defaultAuditCount
+		for _, o := range i.ApplicationCommandData().Options[0].Options {
+			if o.Name == "count" {
+				count = int(o.IntValue())
+			}
+		}
+		// Discord enforces the bounds too, but only once the commands
+		// have been registered again.
+		if count < 1 || count > maxAuditCount {
+			respondEphemeral(s, i, fmt.Sprintf("count has to be between 1 and %v", maxAuditCount))
+			return
+		}
+
+		entries, err := audit.Recent(count)
+		if err != nil {
+			log.WithError(err).Error("unable to read audit log")
+			respondEphemeral(s, i, "unable to read the audit log")
+			return
+		}
+		if len(entries) == 0 {
+			respondEphemeral(s, i, "nothing has been audited yet")
+			return
+		}
+
+		// Newest first, so truncation drops the oldest entries.
+		lines := make([]string, len(entries))
+		for n, e := range entries {
+			lines[len(entries)-1-n] = e.String()
+		}
+		respondEphemeral(s, i, fmt.Sprintf("```\n%v\n```", truncate(strings.Join(lines, "\n"), maxMessageLength-8)))
+	}
+}
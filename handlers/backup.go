@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	log "github.com/sirupsen/logrus"
+)
+
+const maxListedBackups = 15
+
+func Backup(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	start := time.Now()
+	subcommand := i.ApplicationCommandData().Options[0]
+
+	switch subcommand.Name {
+	case "create":
+		// Manual backups are never pruned, so only admins can pile them up.
+		if !isAdmin(i) {
+			metrics.ObserveCommand("backup create", start, outcomeFailure)
+			respondEphemeral(s, i, "only admins can create backups :no_entry:")
+			return
+		}
+
+		content := "snapshotting the server's disk (this might take a few minutes)... "
+		respond(s, i, content)
+
+		var triggeredBy string
+		if u := invoker(i); u != nil {
+			triggeredBy = u.ID
+		}
+		backup, err := server.CreateBackup(server.BackupManual, triggeredBy)
+		outcome := outcomeSuccess
+		res := fmt.Sprintf("done! backup `%v` created", backup.Name)
+		if err != nil {
+			outcome = outcomeFailure
+			res = backupErrorMessage(err)
+		}
+		metrics.ObserveCommand("backup create", start, outcome)
+		auditCommand(s, i, "backup create", nil, start, outcome, errorDetail(err))
+		editResponse(s, i, content+res)
+
+	case "list":
+		defer metrics.ObserveCommand("backup list", start, outcomeSuccess)
+
+		backups, err := server.ListBackups()
+		if err != nil {
+			log.WithError(err).Error("unable to list backups")
+			respond(s, i, "unable to list backups")
+			return
+		}
+		if len(backups) == 0 {
+			respond(s, i, "there are no backups yet, make one with `/backup create`")
+			return
+		}
+		if len(backups) > maxListedBackups {
+			backups = backups[:maxListedBackups]
+		}
+
+		lines := make([]string, len(backups))
+		for n, b := range backups {
+			lines[n] = fmt.Sprintf("%v  %-6v  %v  %vGB  %v", b.Created.Format("2006-01-02 15:04"), b.Kind, b.Name, b.SizeGb, b.Status)
+		}
+		respond(s, i, fmt.Sprintf("```\n%v\n```", truncate(strings.Join(lines, "\n"), maxMessageLength-8)))
+
+	case "restore":
+		var name string
+		var confirm bool
+		for _, o := range subcommand.Options {
+			switch o.Name {
+			case "name":
+				name = o.StringValue()
+			case "confirm":
+				confirm = o.BoolValue()
+			}
+		}
+
+		if !isAdmin(i) {
+			respondEphemeral(s, i, "only admins can restore backups :no_entry:")
+			return
+		}
+		if !confirm {
+			respondEphemeral(s, i, fmt.Sprintf("restoring `%v` replaces the server's disk. run the command again with `confirm: True` if you're sure", name))
+			return
+		}
+
+		content := fmt.Sprintf("restoring backup `%v` (this might take a few minutes)... ", name)
+		respond(s, i, content)
+
+		oldDisk, err := server.RestoreBackup(name)
+		outcome := outcomeSuccess
+		res := fmt.Sprintf("done! the old disk was kept as `%v` in case you need it", oldDisk)
+		if err != nil {
+			outcome = outcomeFailure
+			res = backupErrorMessage(err)
+		}
+		metrics.ObserveCommand("backup restore", start, outcome)
+		auditCommand(s, i, "backup restore", map[string]string{"name": name}, start, outcome, errorDetail(err))
+		editResponse(s, i, content+res)
+	}
+}
+
+func backupErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, server.ErrNotFound):
+		return "there's no server to back up"
+	case errors.Is(err, server.ErrBackupNotFound):
+		return "that backup doesn't exist, check `/backup list`"
+	case errors.Is(err, server.ErrNotStopped):
+		return "the server has to be stopped first, try `/server down`"
+	}
+	log.WithError(err).Error("backup operation failed")
+	return "failed"
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	"github.com/mirrorkeydev/discord-mc-bot/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// Longest message the MC server accepts in chat.
+const maxChatLength = 256
+
+// Channel mirrored to and from the in-game chat. The bridge is off when
+// it isn't set.
+var chatChannelID string
+
+// Set up variables, loading from environment where necessary
+func init() {
+	chatChannelID = os.Getenv("DISCORD_CHAT_CHANNEL_ID")
+}
+
+// Sends chat into the game. Replaced in tests.
+var sendChat = server.SendChat
+
+var customEmoji = regexp.MustCompile(`<a?(:\w+:)\d+>`)
+
+// Mirrors the in-game chat into the chat channel.
+func BridgeChat(s *discordgo.Session) {
+	if chatChannelID == "" {
+		return
+	}
+	server.WatchChat(func(m server.ChatMessage) {
+		// Messages relayed from Discord would otherwise come straight back.
+		if m.External {
+			return
+		}
+		content := fmt.Sprintf("**%v**: %v", escapeMarkdown(m.Player), sanitizeMentions(m.Message))
+		_, err := s.ChannelMessageSendComplex(chatChannelID, &discordgo.MessageSend{
+			Content:         truncate(content, maxMessageLength),
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		})
+		if err != nil {
+			log.WithError(err).Error("unable to relay chat message to discord")
+		}
+	})
+}
+
+// Relays messages posted in the chat channel into the game, under the
+// author's linked Minecraft name if they have one. Needs the message content
+// intent, or Discord sends every message with empty content.
+func RelayToMinecraft(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if chatChannelID == "" || m.ChannelID != chatChannelID {
+		return
+	}
+	// Skip bots and webhooks, which includes the bridge's own messages.
+	if m.Author == nil || m.Author.Bot || m.WebhookID != "" {
+		return
+	}
+
+	author, err := storage.MinecraftName(store, m.Author.ID)
+	if err != nil {
+		log.WithError(err).Error("unable to look up linked minecraft name")
+	}
+	if author == "" {
+		author = m.Author.Username
+		if m.Member != nil && m.Member.Nick != "" {
+			author = m.Member.Nick
+		}
+	}
+
+	content, err := m.ContentWithMoreMentionsReplaced(s)
+	if err != nil {
+		content = m.ContentWithMentionsReplaced()
+	}
+	content = customEmoji.ReplaceAllString(content, "$1")
+	if len(m.Attachments) > 0 {
+		content += " [attachment]"
+	}
+	content = minecraftText(content)
+	if content == "" {
+		return
+	}
+
+	err = sendChat(minecraftText(author), truncate(content, maxChatLength))
+	if errors.Is(err, server.ErrManagementUnavailable) {
+		log.WithError(err).Debug("server is down, not relaying chat message")
+	} else if err != nil {
+		log.WithError(err).Error("unable to relay chat message to minecraft")
+	}
+}
+
+// Links the invoker's Discord account to a Minecraft name, which is then
+// used for them in game.
+func Link(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	start := time.Now()
+	name := i.ApplicationCommandData().Options[0].StringValue()
+	u := invoker(i)
+
+	if !server.ValidMinecraftName(name) {
+		metrics.ObserveCommand("link", start, outcomeFailure)
+		respondEphemeral(s, i, fmt.Sprintf("%v isn't a valid Minecraft username", name))
+		return
+	}
+
+	err := storage.LinkAccount(store, u.ID, storage.Account{MinecraftName: name, Linked: start})
+	if err != nil {
+		log.WithError(err).Error("unable to link minecraft account")
+		metrics.ObserveCommand("link", start, outcomeFailure)
+		respondEphemeral(s, i, "failed")
+		return
+	}
+	metrics.ObserveCommand("link", start, outcomeSuccess)
+	respondEphemeral(s, i, fmt.Sprintf("done! you're %v in game now", name))
+}
+
+// Breaks @everyone, @here, user and role mentions without changing how
+// the text reads.
+func sanitizeMentions(s string) string {
+	return strings.ReplaceAll(s, "@", "@\u200b")
+}
+
+var markdown = strings.NewReplacer("\\", "\\\\", "*", "\\*", "_", "\\_", "~", "\\~", "`", "\\`", "|", "\\|")
+
+func escapeMarkdown(s string) string {
+	return markdown.Replace(s)
+}
+
+// Flattens text onto one line and strips Minecraft formatting codes.
+func minecraftText(s string) string {
+	s = strings.ReplaceAll(s, "§", "")
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/storage"
+)
+
+func TestRelayToMinecraft(t *testing.T) {
+	defer func(channel string, send func(string, string) error, st storage.Store) {
+		chatChannelID, sendChat, store = channel, send, st
+	}(chatChannelID, sendChat, store)
+	chatChannelID = "chat"
+	store = storage.NewMemoryStore()
+	err := storage.LinkAccount(store, "linked", storage.Account{MinecraftName: "Steve", Linked: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := discordgo.New("Bot token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alex := &discordgo.User{ID: "unlinked", Username: "alex"}
+	tests := []struct {
+		name    string
+		message *discordgo.Message
+		// Empty when nothing should be relayed.
+		wantAuthor, wantMessage string
+	}{
+		{
+			name:        "plain",
+			message:     &discordgo.Message{ChannelID: "chat", Author: alex, Content: "hello  there\nfriends"},
+			wantAuthor:  "alex",
+			wantMessage: "hello there friends",
+		},
+		{
+			name:        "linked",
+			message:     &discordgo.Message{ChannelID: "chat", Author: &discordgo.User{ID: "linked", Username: "steve_irl"}, Content: "hi"},
+			wantAuthor:  "Steve",
+			wantMessage: "hi",
+		},
+		{
+			name:        "nickname",
+			message:     &discordgo.Message{ChannelID: "chat", Author: alex, Member: &discordgo.Member{Nick: "Al"}, Content: "hi"},
+			wantAuthor:  "Al",
+			wantMessage: "hi",
+		},
+		{
+			name: "mentions and emoji",
+			message: &discordgo.Message{
+				ChannelID: "chat",
+				Author:    alex,
+				Content:   "<@linked> look <:creeper:1234>",
+				Mentions:  []*discordgo.User{{ID: "linked", Username: "steve_irl"}},
+			},
+			wantAuthor:  "alex",
+			wantMessage: "@steve_irl look :creeper:",
+		},
+		{
+			name:        "attachment only",
+			message:     &discordgo.Message{ChannelID: "chat", Author: alex, Attachments: []*discordgo.MessageAttachment{{}}},
+			wantAuthor:  "alex",
+			wantMessage: "[attachment]",
+		},
+		{
+			// What every message looks like without the message content intent.
+			name:    "no content",
+			message: &discordgo.Message{ChannelID: "chat", Author: alex},
+		},
+		{
+			name:    "other channel",
+			message: &discordgo.Message{ChannelID: "general", Author: alex, Content: "hi"},
+		},
+		{
+			name:    "bot",
+			message: &discordgo.Message{ChannelID: "chat", Author: &discordgo.User{ID: "bot", Bot: true}, Content: "hi"},
+		},
+		{
+			name:    "webhook",
+			message: &discordgo.Message{ChannelID: "chat", Author: alex, WebhookID: "hook", Content: "hi"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var author, message string
+			sendChat = func(a, m string) error {
+				author, message = a, m
+				return nil
+			}
+
+			RelayToMinecraft(s, &discordgo.MessageCreate{Message: test.message})
+			if author != test.wantAuthor || message != test.wantMessage {
+				t.Errorf("relayed %q: %q, want %q: %q", author, message, test.wantAuthor, test.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	log "github.com/sirupsen/logrus"
+)
+
+// Commands that /console refuses unless CONSOLE_DENY says otherwise. The
+// server's lifecycle is managed through /server instead.
+const defaultConsoleDeny = "stop,restart"
+
+// If set, the only commands /console runs. Checked before the denylist.
+var consoleAllow map[string]bool
+var consoleDeny map[string]bool
+
+// Set up variables, loading from environment where necessary
+func init() {
+	consoleAllow = commandSet(os.Getenv("CONSOLE_ALLOW"))
+	deny, ok := os.LookupEnv("CONSOLE_DENY")
+	if !ok {
+		deny = defaultConsoleDeny
+	}
+	consoleDeny = commandSet(deny)
+}
+
+func commandSet(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, c := range strings.Split(list, ",") {
+		if c = strings.ToLower(strings.TrimSpace(c)); c != "" {
+			set[c] = true
+		}
+	}
+	return set
+}
+
+// Returns the names of the commands that running command would run: the
+// command itself and, for execute, whatever follows each run. Namespaces
+// are dropped, so minecraft:stop is stop.
+func consoleCommandNames(command string) []string {
+	fields := strings.Fields(strings.ToLower(command))
+	if len(fields) == 0 {
+		return nil
+	}
+	names := []string{commandName(fields[0])}
+	if names[0] == "execute" {
+		// Every word after a run counts, even if it turns out to be an
+		// argument; refusing too much is better than too little.
+		for n := 1; n+1 < len(fields); n++ {
+			if fields[n] == "run" {
+				names = append(names, commandName(fields[n+1]))
+			}
+		}
+	}
+	return names
+}
+
+func commandName(field string) string {
+	field = strings.TrimPrefix(field, "/")
+	if n := strings.LastIndex(field, ":"); n >= 0 {
+		field = field[n+1:]
+	}
+	return field
+}
+
+// Returns the first command that running command would run but that isn't
+// allowed from Discord. ok is false if there is one, or if command is
+// empty.
+func checkConsoleCommand(command string) (refused string, ok bool) {
+	names := consoleCommandNames(command)
+	if len(names) == 0 {
+		return "", false
+	}
+	for _, name := range names {
+		if (len(consoleAllow) > 0 && !consoleAllow[name]) || consoleDeny[name] {
+			return name, false
+		}
+	}
+	return "", true
+}
+
+func Console(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	start := time.Now()
+	command := strings.TrimPrefix(strings.TrimSpace(i.ApplicationCommandData().Options[0].StringValue()), "/")
+
+	logger := log.WithField("command", command)
+	if u := invoker(i); u != nil {
+		logger = logger.WithField("user", u.String())
+	}
+
+	if !isAdmin(i) {
+		logger.Warn("Refused console command from non-admin")
+		metrics.ObserveCommand("console", start, outcomeFailure)
+		respondEphemeral(s, i, "only admins can do that :no_entry:")
+		return
+	}
+	if refused, ok := checkConsoleCommand(command); !ok {
+		logger.Warn("Refused console command that isn't allowed")
+		metrics.ObserveCommand("console", start, outcomeFailure)
+		auditCommand(s, i, "console", map[string]string{"command": command}, start, outcomeFailure, "command not allowed")
+		respondEphemeral(s, i, fmt.Sprintf("`%v` isn't allowed from Discord", refused))
+		return
+	}
+
+	logger.Info("Running console command")
+	respondEphemeral(s, i, fmt.Sprintf("running `%v`...", command))
+
+	out, err := server.RunCommand(command)
+	outcome := outcomeSuccess
+	var content string
+	if err != nil {
+		logger.WithError(err).Error("unable to run console command")
+		outcome = outcomeFailure
+		content = "failed: " + err.Error()
+	} else {
+		out = strings.TrimSpace(server.StripFormatting(out))
+		if out == "" {
+			out = "(no output)"
+		}
+		out = strings.ReplaceAll(out, "```", "``\u200b`")
+		content = fmt.Sprintf("```\n%v\n```", truncate(out, maxMessageLength-len("```\n\n```")))
+	}
+	metrics.ObserveCommand("console", start, outcome)
+	auditCommand(s, i, "console", map[string]string{"command": command}, start, outcome, errorDetail(err))
+	editResponse(s, i, content)
+}
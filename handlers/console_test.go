@@ -0,0 +1,45 @@
+package handlers
+
+import "testing"
+
+func TestCheckConsoleCommand(t *testing.T) {
+	defer func(allow, deny map[string]bool) {
+		consoleAllow, consoleDeny = allow, deny
+	}(consoleAllow, consoleDeny)
+
+	tests := []struct {
+		name        string
+		allow       string
+		command     string
+		wantRefused string
+		wantOK      bool
+	}{
+		{name: "allowed", command: "say hello", wantOK: true},
+		{name: "empty", command: "  ", wantOK: false},
+		{name: "denied", command: "stop", wantRefused: "stop"},
+		{name: "denied in caps", command: "STOP", wantRefused: "stop"},
+		{name: "namespaced", command: "minecraft:stop", wantRefused: "stop"},
+		{name: "slash", command: "/minecraft:restart", wantRefused: "restart"},
+		{name: "execute run", command: "execute as @a run stop", wantRefused: "stop"},
+		{name: "execute run namespaced", command: "execute at @p run minecraft:stop", wantRefused: "stop"},
+		{name: "nested execute", command: "execute as @a run execute at @s run stop", wantRefused: "stop"},
+		{name: "run as an argument", command: "execute as run run stop", wantRefused: "stop"},
+		{name: "execute allowed", command: "execute as @a run say hi", wantOK: true},
+		{name: "allowlist", allow: "list,say", command: "list", wantOK: true},
+		{name: "not on allowlist", allow: "list,say", command: "op Steve", wantRefused: "op"},
+		{name: "execute not on allowlist", allow: "list,say", command: "execute run say hi", wantRefused: "execute"},
+		{name: "allowlisted execute", allow: "execute,say", command: "execute run op Steve", wantRefused: "op"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			consoleAllow = commandSet(test.allow)
+			consoleDeny = commandSet(defaultConsoleDeny)
+
+			refused, ok := checkConsoleCommand(test.command)
+			if refused != test.wantRefused || ok != test.wantOK {
+				t.Errorf("checkConsoleCommand(%q) = %q, %v, want %q, %v", test.command, refused, ok, test.wantRefused, test.wantOK)
+			}
+		})
+	}
+}
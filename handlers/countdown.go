@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultShutdownCountdown = "5m,1m,10s"
+
+// How long before a shutdown players are warned, longest first.
+var shutdownWarnings []time.Duration
+
+// Set up the shutdown countdown, loading from environment where necessary.
+// SHUTDOWN_COUNTDOWN=off brings the server down without warning.
+func init() {
+	spec := os.Getenv("SHUTDOWN_COUNTDOWN")
+	if spec == "" {
+		spec = defaultShutdownCountdown
+	}
+	if spec == "off" {
+		return
+	}
+	for _, part := range strings.Split(spec, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || d <= 0 {
+			log.Fatalf("Environment Variable SHUTDOWN_COUNTDOWN must be a list of durations like %v, or off.", defaultShutdownCountdown)
+		}
+		shutdownWarnings = append(shutdownWarnings, d)
+	}
+	sort.Slice(shutdownWarnings, func(a, b int) bool { return shutdownWarnings[a] > shutdownWarnings[b] })
+}
+
+var errShutdownPending = errors.New("a shutdown is already counting down")
+
+// Returned, wrapped, when the countdown was skipped because nobody in game
+// could be reached. The shutdown can still go ahead.
+var errCannotWarnPlayers = errors.New("cannot warn players")
+
+// Replaced in tests.
+var instanceStatus = server.InstanceStatus
+var playerCount = server.PlayerCount
+
+// Custom ID of the button that cancels a shutdown counting down.
+const CancelShutdownID = "cancel-shutdown"
+
+var cancelShutdownButton = []discordgo.MessageComponent{
+	discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "Cancel shutdown",
+				Style:    discordgo.DangerButton,
+				CustomID: CancelShutdownID,
+			},
+		},
+	},
+}
+
+// A /server down waiting for its countdown to finish.
+type shutdownCountdown struct {
+	cancel      chan struct{}
+	cancelledBy string
+}
+
+var pendingShutdown struct {
+	sync.Mutex
+	countdown *shutdownCountdown
+}
+
+// Warns players in-game at each configured interval before a shutdown,
+// calling notify with the time left so Discord can be told too. The
+// countdown is skipped when the instance isn't running or nobody is online,
+// and also when the player count can't be fetched, in which case
+// errCannotWarnPlayers is returned. If it is cancelled, with the button or
+// /server cancel, the name of whoever cancelled it is returned.
+func countDownToShutdown(notify func(left time.Duration)) (cancelledBy string, err error) {
+	c := &shutdownCountdown{cancel: make(chan struct{})}
+	pendingShutdown.Lock()
+	if pendingShutdown.countdown != nil {
+		pendingShutdown.Unlock()
+		return "", errShutdownPending
+	}
+	pendingShutdown.countdown = c
+	pendingShutdown.Unlock()
+
+	defer func() {
+		pendingShutdown.Lock()
+		if pendingShutdown.countdown == c {
+			pendingShutdown.countdown = nil
+		}
+		pendingShutdown.Unlock()
+	}()
+
+	if len(shutdownWarnings) == 0 {
+		return "", nil
+	}
+	status, err := instanceStatus()
+	if err != nil || status != "RUNNING" {
+		log.WithField("status", status).Info("Server isn't running, skipping the shutdown countdown. ")
+		return "", nil
+	}
+	players, err := playerCount()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errCannotWarnPlayers, err)
+	}
+	if players == 0 {
+		log.Info("Nobody is online, skipping the shutdown countdown. ")
+		return "", nil
+	}
+
+	for n, left := range shutdownWarnings {
+		notify(left)
+		err := server.Broadcast(fmt.Sprintf("The server is shutting down in %v!", formatCountdown(left)))
+		if err != nil {
+			log.WithError(err).Warn("unable to warn players about the shutdown")
+		}
+
+		var next time.Duration
+		if n+1 < len(shutdownWarnings) {
+			next = shutdownWarnings[n+1]
+		}
+		select {
+		case <-time.After(left - next):
+		case <-c.cancel:
+			err := server.Broadcast("The shutdown was cancelled, carry on!")
+			if err != nil {
+				log.WithError(err).Warn("unable to tell players the shutdown was cancelled")
+			}
+			return c.cancelledBy, nil
+		}
+	}
+	return "", nil
+}
+
+// Runs the shutdown countdown for the command that i is, whose response so
+// far is content, showing the time left and a cancel button. Returns what
+// to add to the response, and if the shutdown mustn't go ahead, the reply
+// and outcome for the command.
+func countDownInResponse(s *discordgo.Session, i *discordgo.InteractionCreate, content string) (note string, res string, outcome string, err error) {
+	cancelledBy, err := countDownToShutdown(func(left time.Duration) {
+		editResponseWithComponents(s, i, fmt.Sprintf("%vshutting down in %v... ", content, formatCountdown(left)), cancelShutdownButton)
+	})
+	switch {
+	case errors.Is(err, errCannotWarnPlayers):
+		log.WithError(err).Warn("unable to check for players before shutting down")
+		return "(couldn't reach the game to warn anyone) ", "", outcomeSuccess, nil
+	case errors.Is(err, errShutdownPending):
+		return "", "a shutdown is already counting down, use `/server cancel` to stop it", outcomeFailure, err
+	case cancelledBy != "":
+		return "", fmt.Sprintf("cancelled by %v", cancelledBy), outcomeCancelled, nil
+	}
+	return "", "", outcomeSuccess, nil
+}
+
+// Cancels the pending shutdown, if there is one.
+func cancelShutdown(by string) bool {
+	pendingShutdown.Lock()
+	defer pendingShutdown.Unlock()
+
+	c := pendingShutdown.countdown
+	if c == nil {
+		return false
+	}
+	c.cancelledBy = by
+	close(c.cancel)
+	pendingShutdown.countdown = nil
+	return true
+}
+
+func formatCountdown(d time.Duration) string {
+	switch {
+	case d == time.Minute:
+		return "1 minute"
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	case d == time.Second:
+		return "1 second"
+	default:
+		return fmt.Sprintf("%d seconds", d/time.Second)
+	}
+}
+
+// Cancels the pending shutdown on behalf of whoever sent i. If there is
+// none they are told so, and false is returned.
+func cancelShutdownFor(s *discordgo.Session, i *discordgo.InteractionCreate, via string) bool {
+	start := time.Now()
+
+	by := "someone"
+	if u := invoker(i); u != nil {
+		by = u.Username
+	}
+	if !cancelShutdown(by) {
+		metrics.ObserveCommand("server cancel", start, outcomeFailure)
+		respondEphemeral(s, i, "there's no shutdown counting down right now")
+		return false
+	}
+
+	metrics.ObserveCommand("server cancel", start, outcomeSuccess)
+	auditCommand(s, i, "server cancel", map[string]string{"via": via}, start, outcomeSuccess, "")
+	return true
+}
+
+func serverCancel(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if cancelShutdownFor(s, i, "command") {
+		respond(s, i, "shutdown cancelled :relieved:")
+	}
+}
+
+// Handles the cancel button on a /server down countdown. The countdown
+// message itself says who cancelled once /server down notices.
+func CancelShutdown(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if cancelShutdownFor(s, i, "button") {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredMessageUpdate,
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+)
+
+func TestCountDownToShutdownSkipped(t *testing.T) {
+	defer func(warnings []time.Duration, status func() (string, error), players func() (uint32, error)) {
+		shutdownWarnings, instanceStatus, playerCount = warnings, status, players
+	}(shutdownWarnings, instanceStatus, playerCount)
+	shutdownWarnings = []time.Duration{time.Hour}
+
+	tests := []struct {
+		name       string
+		status     string
+		statusErr  error
+		players    uint32
+		playersErr error
+		wantErr    error
+	}{
+		{name: "no instance", statusErr: server.ErrNotFound},
+		{name: "stopped", status: "TERMINATED"},
+		{name: "suspended", status: "SUSPENDED"},
+		{name: "nobody online", status: "RUNNING"},
+		{name: "player count fails", status: "RUNNING", playersErr: server.ErrManagementUnavailable, wantErr: errCannotWarnPlayers},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instanceStatus = func() (string, error) { return test.status, test.statusErr }
+			playerCount = func() (uint32, error) { return test.players, test.playersErr }
+
+			warned := false
+			cancelledBy, err := countDownToShutdown(func(time.Duration) {
+				warned = true
+				cancelShutdown("test")
+			})
+			if warned || cancelledBy != "" {
+				t.Error("counted down instead of skipping")
+			}
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("got %v, want %v", err, test.wantErr)
+			}
+			if cancelShutdown("test") {
+				t.Error("skipped countdown was left pending")
+			}
+		})
+	}
+}
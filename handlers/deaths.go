@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	"github.com/mirrorkeydev/discord-mc-bot/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	weeklyDeathsReport      = "weekly-deaths"
+	weeklyReportCheckPeriod = time.Hour
+	topCauses               = 3
+)
+
+// Records every player death, and posts who died the most each week.
+func WatchDeaths(s *discordgo.Session) {
+	go postWeeklyDeaths(s)
+	server.WatchPlayerEvents(func(e server.DeathEvent) {
+		err := storage.AddDeath(store, storage.Death{
+			Time:    e.Time,
+			Player:  e.Player,
+			Message: e.Message,
+			Cause:   deathCause(e.Player, e.Message),
+		})
+		if err != nil {
+			log.WithError(err).Errorf("unable to record death of %v", e.Player)
+		}
+	})
+}
+
+// Pulls what killed the player out of a death message, dropping the
+// player's name and details like the weapon used, so that e.g. "Steve was
+// shot by Skeleton using Bow" and "Alex was shot by Skeleton" count as the
+// same cause.
+func deathCause(player, message string) string {
+	cause := strings.TrimPrefix(strings.TrimSpace(message), player+" ")
+	for _, detail := range []string{" using ", " whilst ", " while "} {
+		if n := strings.Index(cause, detail); n >= 0 {
+			cause = cause[:n]
+		}
+	}
+	return strings.TrimSuffix(cause, ".")
+}
+
+type tally struct {
+	name  string
+	count int
+}
+
+// Counts deaths by key, most common first.
+func countDeaths(deaths []storage.Death, key func(storage.Death) string) []tally {
+	counts := make(map[string]int)
+	for _, d := range deaths {
+		counts[key(d)]++
+	}
+	tallies := make([]tally, 0, len(counts))
+	for name, count := range counts {
+		tallies = append(tallies, tally{name, count})
+	}
+	sort.Slice(tallies, func(a, b int) bool {
+		if tallies[a].count != tallies[b].count {
+			return tallies[a].count > tallies[b].count
+		}
+		return tallies[a].name < tallies[b].name
+	})
+	return tallies
+}
+
+func deathLeaderboard(deaths []storage.Death, description string) string {
+	lines := []string{fmt.Sprintf("most deaths in %v:", description)}
+	for n, t := range countDeaths(deaths, func(d storage.Death) string { return d.Player }) {
+		if n == leaderboardSize {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%v. **%v** %v :skull:", n+1, escapeMarkdown(t.name), t.count))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func causesLine(deaths []storage.Death) string {
+	var causes []string
+	for n, t := range countDeaths(deaths, func(d storage.Death) string { return d.Cause }) {
+		if n == topCauses {
+			break
+		}
+		causes = append(causes, fmt.Sprintf("%v (%v)", sanitizeMentions(escapeMarkdown(t.name)), t.count))
+	}
+	return "most common causes: " + strings.Join(causes, ", ")
+}
+
+func Deaths(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	start := time.Now()
+	var player string
+	for _, o := range i.ApplicationCommandData().Options {
+		if o.Name == "player" {
+			player = o.StringValue()
+		}
+	}
+
+	deaths, err := storage.DeathsSince(store, time.Time{})
+	if err != nil {
+		log.WithError(err).Error("unable to read deaths")
+		metrics.ObserveCommand("deaths", start, outcomeFailure)
+		respond(s, i, "unable to read deaths")
+		return
+	}
+	metrics.ObserveCommand("deaths", start, outcomeSuccess)
+
+	if player == "" {
+		if len(deaths) == 0 {
+			respond(s, i, "nobody has died yet :angel:")
+			return
+		}
+		respond(s, i, truncate(fmt.Sprintf("%v deaths so far, %v\n%v", len(deaths), causesLine(deaths), deathLeaderboard(deaths, "all time")), maxMessageLength))
+		return
+	}
+
+	// Minecraft names are case insensitive.
+	var theirs []storage.Death
+	for _, d := range deaths {
+		if strings.EqualFold(d.Player, player) {
+			player = d.Player
+			theirs = append(theirs, d)
+		}
+	}
+	if len(theirs) == 0 {
+		respond(s, i, fmt.Sprintf("**%v** hasn't died yet :angel:", escapeMarkdown(player)))
+		return
+	}
+	respond(s, i, truncate(fmt.Sprintf("**%v** has died %v times, %v\nlast: %v", escapeMarkdown(player), len(theirs), causesLine(theirs),
+		sanitizeMentions(escapeMarkdown(theirs[len(theirs)-1].Message))), maxMessageLength))
+}
+
+// Posts a weekly report of who died the most, in the players channel if
+// there is one and the status channel otherwise.
+func postWeeklyDeaths(s *discordgo.Session) {
+	channelID := playersChannelID
+	if channelID == "" {
+		channelID = statusChannelID
+	}
+	if channelID == "" {
+		return
+	}
+
+	for {
+		last, err := storage.LastReport(store, weeklyDeathsReport)
+		if err != nil {
+			log.WithError(err).Error("unable to check when deaths were last reported")
+		} else if last.IsZero() {
+			// Start counting from the first run rather than posting right away.
+			err = storage.SetLastReport(store, weeklyDeathsReport, time.Now())
+		} else if time.Since(last) >= 7*24*time.Hour {
+			err = postDeathsReport(s, channelID, last)
+		}
+		if err != nil {
+			log.WithError(err).Error("unable to post weekly deaths")
+		}
+		time.Sleep(weeklyReportCheckPeriod)
+	}
+}
+
+func postDeathsReport(s *discordgo.Session, channelID string, since time.Time) error {
+	deaths, err := storage.DeathsSince(store, since)
+	if err != nil {
+		return err
+	}
+	if len(deaths) > 0 {
+		_, err = s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Content:         truncate(fmt.Sprintf("%v\n%v", deathLeaderboard(deaths, "the last week"), causesLine(deaths)), maxMessageLength),
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return storage.SetLastReport(store, weeklyDeathsReport, time.Now())
+}
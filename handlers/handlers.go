@@ -5,111 +5,168 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
 	"github.com/mirrorkeydev/discord-mc-bot/server"
 	log "github.com/sirupsen/logrus"
 )
 
+// Outcome labels recorded for each command invocation.
+const (
+	outcomeSuccess   = "success"
+	outcomeFailure   = "failure"
+	outcomeCancelled = "cancelled"
+)
+
 func Ping(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	defer metrics.ObserveCommand("ping", time.Now(), outcomeSuccess)
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionApplicationCommandResponseData{
+		Data: &discordgo.InteractionResponseData{
 			Content: "pong :ping_pong:",
 		},
 	})
 }
 
 func Version(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	defer metrics.ObserveCommand("version", time.Now(), outcomeSuccess)
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionApplicationCommandResponseData{
+		Data: &discordgo.InteractionResponseData{
 			Content: "v1.1.1 :v:",
 		},
 	})
 }
 
 func Server(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	switch i.ApplicationCommandData().Options[0].Name {
+	case "resize":
+		serverResize(s, i)
+		return
+	case "restart":
+		serverRestart(s, i)
+		return
+	case "reset":
+		serverReset(s, i)
+		return
+	case "cancel":
+		serverCancel(s, i)
+		return
+	case "status":
+		serverStatus(s, i)
+		return
+	}
+
+	start := time.Now()
 	content := ""
-	switch i.Data.Options[0].Name {
+	switch i.ApplicationCommandData().Options[0].Name {
 	case "up":
 		content = "bringing up the server... "
 	case "down":
 		content = "bringing down the server (this might take a minute or two)... "
+	case "suspend":
+		content = "suspending the server (everything stays in memory, so it comes back quickly)... "
 	default:
 		content = "something has gone wrong, and you executed a command that doesn't exist. Congrats! :tada:"
 	}
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionApplicationCommandResponseData{
+		Data: &discordgo.InteractionResponseData{
 			Content: content,
 		},
 	})
 
-	var success bool
 	var res string
-	switch i.Data.Options[0].Name {
+	var opErr error
+	outcome := outcomeSuccess
+	switch i.ApplicationCommandData().Options[0].Name {
 	case "up":
-		success, res = server.BringUpServer()
-		if success {
-			err := s.UpdateGameStatus(0, fmt.Sprintf("server up @ %v", server.ManagementServerAddress))
-			if err != nil {
-				log.WithError(err).Error("unable to update status")
-			}
+		var result server.UpResult
+		result, opErr = server.BringUpServer()
+		res = upMessage(result, opErr)
+		if opErr != nil && !errors.Is(opErr, server.ErrDNSUpdate) {
+			outcome = outcomeFailure
+		} else {
+			recordUptime(i, "up")
+			setGameStatus(s, fmt.Sprintf("server up @ %v", server.ManagementServerAddress))
 		}
 	case "down":
-		success, res = server.BringDownServer()
-		if success {
-			err := s.UpdateGameStatus(0, "server down")
-			if err != nil {
-				log.WithError(err).Error("unable to update status")
-			}
+		var note string
+		note, res, outcome, opErr = countDownInResponse(s, i, content)
+		content += note
+		if res != "" {
+			break
 		}
-	}
 
-	err := s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
-		Content: content + res,
-	})
-	if err != nil {
-		s.FollowupMessageCreate(s.State.User.ID, i.Interaction, true, &discordgo.WebhookParams{
-			Content: "something went wrong",
+		var result server.DownResult
+		result, opErr = server.BringDownServer(func(phase server.Phase) {
+			content += phaseMessage(phase)
+			editResponse(s, i, content)
 		})
-		return
+		res = downMessage(result, opErr)
+		if opErr != nil && !errors.Is(opErr, server.ErrNotFound) {
+			outcome = outcomeFailure
+		} else {
+			recordUptime(i, "down")
+			setGameStatus(s, "server down")
+		}
+	case "suspend":
+		var result server.SuspendResult
+		result, opErr = server.SuspendServer()
+		res = suspendMessage(result, opErr)
+		if opErr != nil {
+			outcome = outcomeFailure
+		} else {
+			recordUptime(i, "suspended")
+			setGameStatus(s, "server suspended")
+		}
+	default:
+		outcome = outcomeFailure
 	}
+	metrics.ObserveCommand("server "+i.ApplicationCommandData().Options[0].Name, start, outcome)
+	auditCommand(s, i, "server "+i.ApplicationCommandData().Options[0].Name, nil, start, outcome, errorDetail(opErr))
+
+	editResponse(s, i, content+res)
 }
 
 func Whitelist(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	playerUsername := i.Data.Options[0].StringValue()
+	start := time.Now()
+	playerUsername := i.ApplicationCommandData().Options[0].StringValue()
 	content := fmt.Sprintf("whitelisting player %v...", playerUsername)
 
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionApplicationCommandResponseData{
+		Data: &discordgo.InteractionResponseData{
 			Content: content,
 		},
 	})
 
 	var res = ""
+	var opErr error
+	outcome := outcomeFailure
 
 	serverIsUp, err := McServerIsUp(s)
 	if err != nil {
+		opErr = err
 		res = "unable to check if MC server is up"
 	} else if !serverIsUp {
 		res = "the server isn't up, so you can't whitelist players. try starting the server first"
 	} else {
-		_, res = server.Whitelist(playerUsername)
+		opErr = server.Whitelist(playerUsername)
+		if opErr == nil {
+			outcome = outcomeSuccess
+		}
+		res = whitelistMessage(playerUsername, opErr)
 	}
+	metrics.ObserveCommand("whitelist", start, outcome)
+	auditCommand(s, i, "whitelist", map[string]string{"user": playerUsername}, start, outcome, errorDetail(opErr))
 
-	err = s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
-		Content: content + res,
-	})
-	if err != nil {
-		s.FollowupMessageCreate(s.State.User.ID, i.Interaction, true, &discordgo.WebhookParams{
-			Content: "something went wrong",
-		})
-		return
-	}
+	editResponse(s, i, content+res)
 }
 
 func McServerIsUp(s *discordgo.Session) (bool, error) {
@@ -117,32 +174,37 @@ func McServerIsUp(s *discordgo.Session) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	UpdatePresence(s, serverIsUp)
+	return serverIsUp, nil
+}
 
+// Shows whether the server is up in the bot's status.
+func UpdatePresence(s *discordgo.Session, serverIsUp bool) {
 	if serverIsUp {
-		err = s.UpdateGameStatus(0, fmt.Sprintf("server up @ %v", server.ManagementServerAddress))
-		if err != nil {
-			log.WithError(err).Error("unable to update status")
-		}
-		return true, nil
+		setGameStatus(s, fmt.Sprintf("server up @ %v", server.ManagementServerAddress))
 	} else {
-		err := s.UpdateGameStatus(0, "server down")
-		if err != nil {
-			log.WithError(err).Error("unable to update status")
-		}
-		return false, nil
+		setGameStatus(s, "server down")
 	}
 }
 
-func Shame(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	userToShame := i.Data.Options[0].UserValue(s)
-	shameMessage := i.Data.Options[1].StringValue()
+// The bot's current status, so unchanged statuses aren't sent to Discord
+// again.
+var gameStatus struct {
+	sync.Mutex
+	current string
+}
 
-	content := fmt.Sprintf("%v, you have been shamed: %v", userToShame.Mention(), shameMessage)
+func setGameStatus(s *discordgo.Session, status string) {
+	gameStatus.Lock()
+	defer gameStatus.Unlock()
 
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionApplicationCommandResponseData{
-			Content: content,
-		},
-	})
+	if status == gameStatus.current {
+		return
+	}
+	err := s.UpdateGameStatus(0, status)
+	if err != nil {
+		log.WithError(err).Error("unable to update status")
+		return
+	}
+	gameStatus.current = status
 }
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	"github.com/mirrorkeydev/discord-mc-bot/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+const leaderboardSize = 10
+
+// Returns when a leaderboard period starts, and how to describe it. All
+// time has no start, which is the zero time.
+func periodStart(period string) (time.Time, string) {
+	switch period {
+	case "week":
+		return time.Now().AddDate(0, 0, -7), "the last week"
+	case "month":
+		return time.Now().AddDate(0, -1, 0), "the last month"
+	default:
+		return time.Time{}, "all time"
+	}
+}
+
+func Leaderboard(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	start := time.Now()
+	period := "all"
+	for _, o := range i.ApplicationCommandData().Options[0].Options {
+		if o.Name == "period" {
+			period = o.StringValue()
+		}
+	}
+	since, description := periodStart(period)
+
+	switch i.ApplicationCommandData().Options[0].Name {
+	case "playtime":
+		totals, err := storage.Playtime(store, since)
+		if err != nil {
+			log.WithError(err).Error("unable to read playtime")
+			metrics.ObserveCommand("leaderboard playtime", start, outcomeFailure)
+			respond(s, i, "unable to read playtime")
+			return
+		}
+		metrics.ObserveCommand("leaderboard playtime", start, outcomeSuccess)
+		if len(totals) == 0 {
+			respond(s, i, fmt.Sprintf("nobody has played in %v :sob:", description))
+			return
+		}
+
+		players := make([]string, 0, len(totals))
+		for p := range totals {
+			players = append(players, p)
+		}
+		sort.Slice(players, func(a, b int) bool { return totals[players[a]] > totals[players[b]] })
+		if len(players) > leaderboardSize {
+			players = players[:leaderboardSize]
+		}
+
+		lines := []string{fmt.Sprintf("most time played in %v:", description)}
+		for n, p := range players {
+			lines = append(lines, fmt.Sprintf("%v. **%v** %v", n+1, escapeMarkdown(p), formatPlaytime(totals[p])))
+		}
+		respond(s, i, strings.Join(lines, "\n"))
+	case "deaths":
+		deaths, err := storage.DeathsSince(store, since)
+		if err != nil {
+			log.WithError(err).Error("unable to read deaths")
+			metrics.ObserveCommand("leaderboard deaths", start, outcomeFailure)
+			respond(s, i, "unable to read deaths")
+			return
+		}
+		metrics.ObserveCommand("leaderboard deaths", start, outcomeSuccess)
+		if len(deaths) == 0 {
+			respond(s, i, fmt.Sprintf("nobody has died in %v :angel:", description))
+			return
+		}
+		respond(s, i, deathLeaderboard(deaths, description))
+	}
+}
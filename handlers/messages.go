@@ -0,0 +1,125 @@
+// User-facing phrasing for results reported by the server package.
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/mirrorkeydev/discord-mc-bot/proto"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	log "github.com/sirupsen/logrus"
+)
+
+func phaseMessage(phase server.Phase) string {
+	switch phase {
+	case server.PhaseStopping:
+		return "stopping... "
+	case server.PhaseStarting:
+		return "starting... "
+	case server.PhaseResizing:
+		return "resizing... "
+	case server.PhaseResetting:
+		return "resetting... "
+	case server.PhaseSaving:
+		return "saving the world... "
+	case server.PhaseWaitingForMinecraft:
+		return "waiting for Minecraft to shut down... "
+	default:
+		return "... "
+	}
+}
+
+func upMessage(res server.UpResult, err error) string {
+	if errors.Is(err, server.ErrDNSUpdate) {
+		log.WithError(err).Error("unable to update the server's DNS record")
+		ip, ipErr := server.ExternalIP()
+		if ipErr != nil {
+			return fmt.Sprintf("the server is up, but I couldn't point %v at it :grimacing:", server.ManagementServerAddress)
+		}
+		return fmt.Sprintf("the server is up, but I couldn't point %v at it. connect to %v directly for now", server.ManagementServerAddress, ip)
+	}
+	if errors.Is(err, server.ErrProvisioning) {
+		log.WithError(err).Error("unable to create the server")
+		return "there's no instance, and I'm not set up to create one. check the bot's logs"
+	}
+	if err != nil {
+		log.WithError(err).Error("unable to bring up the server")
+		return "failed"
+	}
+	switch res {
+	case server.UpAlreadyRunning:
+		return "instance was already running :clown:"
+	case server.UpResumed:
+		return "done! the server was resumed right where it left off :zap:"
+	case server.UpCreated:
+		return "done! a brand new instance was created, please go do something else for 5 minutes while it boots up Minecraft"
+	default:
+		return "done! please go do something else for 5 minutes, the server instance is booting up Minecraft"
+	}
+}
+
+func downMessage(res server.DownResult, err error) string {
+	if err != nil {
+		switch {
+		case errors.Is(err, server.ErrNotFound):
+			return "it already didn't exist"
+		case errors.Is(err, server.ErrSuspended):
+			return "the server is suspended, so it isn't using any CPU. use `/server up` to resume it"
+		}
+		log.WithError(err).Error("unable to bring down the server")
+		return "failed"
+	}
+	switch res {
+	case server.DownAlreadyStopped:
+		return "it was already stopped!"
+	case server.DownStoppedUnclean:
+		return "done, but Minecraft didn't confirm it saved the world before the server went down :warning:"
+	default:
+		return "done!"
+	}
+}
+
+func suspendMessage(res server.SuspendResult, err error) string {
+	if err != nil {
+		switch {
+		case errors.Is(err, server.ErrNotFound):
+			return "there's no server to suspend"
+		case errors.Is(err, server.ErrNotRunning):
+			return "the server isn't running, so there's nothing to suspend"
+		}
+		log.WithError(err).Error("unable to suspend the server")
+		return "failed"
+	}
+	switch res {
+	case server.SuspendAlreadySuspended:
+		return "it was already suspended!"
+	default:
+		return "done! use `/server up` to resume it"
+	}
+}
+
+func whitelistMessage(player string, err error) string {
+	if err == nil {
+		return "done!"
+	}
+	log.WithError(err).Errorf("could not whitelist %v", player)
+
+	if errors.Is(err, server.ErrManagementUnavailable) {
+		return "unable to connect to management server"
+	}
+	var wErr *server.WhitelistError
+	if errors.As(err, &wErr) {
+		switch wErr.Code {
+		case pb.UpdateWhitelistResponse_DUP_ADD:
+			return fmt.Sprintf("%v is already whitelisted :eyes:", player)
+		case pb.UpdateWhitelistResponse_INVAL_NAME, pb.UpdateWhitelistResponse_INVAL_MC_USER:
+			return fmt.Sprintf("%v isn't a valid Minecraft username", player)
+		case pb.UpdateWhitelistResponse_TIMEOUT:
+			return "the Minecraft server took too long to respond, try again in a bit"
+		default:
+			return fmt.Sprintf("whitelist operation failed: %v", wErr.Response)
+		}
+	}
+	return fmt.Sprintf("whitelist operation failed: %v", err)
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	"github.com/mirrorkeydev/discord-mc-bot/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// How often open sessions are marked as still going.
+const sessionKeepAliveInterval = time.Minute
+
+// Records a session for each stretch of time a player is online.
+type playtimeTracker struct {
+	mutex sync.Mutex
+	open  map[string]storage.Session
+}
+
+// Sessions left open by a previous run are closed when their player was
+// last seen, since there's no telling what happened while the bot was
+// away.
+func newPlaytimeTracker() *playtimeTracker {
+	open, err := storage.OpenSessions(store)
+	if err != nil {
+		log.WithError(err).Error("unable to read open sessions")
+	}
+	for _, sess := range open {
+		sess.End = sess.LastSeen
+		err := storage.PutSession(store, sess)
+		if err != nil {
+			log.WithError(err).Error("unable to close session")
+		}
+	}
+	return &playtimeTracker{open: make(map[string]storage.Session)}
+}
+
+// Starts sessions for players who came online and ends them for players
+// who went offline. Losing the subscription, e.g. because the instance
+// stopped, ends every open session.
+func (p *playtimeTracker) update(u server.PlayerUpdate) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	current := make(map[string]bool)
+	for _, name := range u.Players {
+		current[name] = true
+		if _, ok := p.open[name]; ok {
+			continue
+		}
+		sess := storage.Session{Player: name, Start: u.Time, LastSeen: u.Time}
+		p.open[name] = sess
+		p.save(sess)
+	}
+	for name, sess := range p.open {
+		if current[name] {
+			continue
+		}
+		sess.End = u.Time
+		sess.LastSeen = u.Time
+		delete(p.open, name)
+		p.save(sess)
+	}
+}
+
+func (p *playtimeTracker) keepAlive() {
+	for range time.Tick(sessionKeepAliveInterval) {
+		p.mutex.Lock()
+		now := time.Now()
+		for name, sess := range p.open {
+			sess.LastSeen = now
+			p.open[name] = sess
+			p.save(sess)
+		}
+		p.mutex.Unlock()
+	}
+}
+
+func (p *playtimeTracker) save(sess storage.Session) {
+	err := storage.PutSession(store, sess)
+	if err != nil {
+		log.WithError(err).Errorf("unable to record session for %v", sess.Player)
+	}
+}
+
+func formatPlaytime(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%dh %dm", d/time.Hour, (d%time.Hour)/time.Minute)
+}
+
+func Playtime(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	start := time.Now()
+	player := i.ApplicationCommandData().Options[0].StringValue()
+
+	var lines []string
+	for _, period := range []string{"week", "month", "all"} {
+		since, description := periodStart(period)
+		totals, err := storage.Playtime(store, since)
+		if err != nil {
+			log.WithError(err).Error("unable to read playtime")
+			metrics.ObserveCommand("playtime", start, outcomeFailure)
+			respond(s, i, "unable to read playtime")
+			return
+		}
+		// Minecraft names are case insensitive.
+		var total time.Duration
+		for p, d := range totals {
+			if strings.EqualFold(p, player) {
+				player = p
+				total += d
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%v: %v", description, formatPlaytime(total)))
+	}
+	metrics.ObserveCommand("playtime", start, outcomeSuccess)
+	respond(s, i, fmt.Sprintf("**%v** has played for\n%v", escapeMarkdown(player), strings.Join(lines, "\n")))
+}
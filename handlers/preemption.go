@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	log "github.com/sirupsen/logrus"
+)
+
+// Announces spot VM preemptions, and the automatic restarts that follow,
+// in the status channel.
+func WatchPreemptions(s *discordgo.Session) {
+	server.WatchPreemptions(func(e server.PreemptionEvent) {
+		var content string
+		switch e.Type {
+		case server.Preempted:
+			content = "GCP took the server back (it's a spot VM) :cloud_rain:"
+			if !e.Confirmed {
+				content = "the server stopped without anyone asking it to :thinking:"
+			}
+			if e.Attempt > 0 {
+				content += fmt.Sprintf(" restarting it (attempt %v of %v)...", e.Attempt, e.MaxAttempts)
+			} else if e.MaxAttempts > 0 {
+				content += " it's been restarted too many times in a row, so I'm leaving it down. use `/server up` to try again"
+			}
+			McServerIsUp(s)
+		case server.PreemptionRestarted:
+			content = fmt.Sprintf("the server is back up @ %v", server.ManagementServerAddress)
+			McServerIsUp(s)
+		case server.PreemptionRestartFailed:
+			log.WithError(e.Err).Error("unable to restart preempted server")
+			if e.RetryIn > 0 {
+				content = fmt.Sprintf("couldn't restart the server (attempt %v of %v), trying again in %v...", e.Attempt, e.MaxAttempts, formatCountdown(e.RetryIn))
+			} else {
+				content = fmt.Sprintf("couldn't restart the server after %v attempts :pensive: try `/server up` in a bit", e.Attempt)
+			}
+		}
+
+		log.Info(content)
+		if statusChannelID == "" {
+			return
+		}
+		_, err := s.ChannelMessageSend(statusChannelID, content)
+		if err != nil {
+			log.WithError(err).Error("unable to announce preemption")
+		}
+	})
+}
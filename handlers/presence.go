@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultPresenceDebounce = time.Minute
+
+// Channel where players joining and leaving are announced. Announcements
+// are off when it isn't set.
+var playersChannelID string
+
+// How long a player has to be gone before they're announced as having
+// left, so a quick reconnect doesn't post anything.
+var presenceDebounce time.Duration
+
+// Hours of the day, in the bot's local time, during which announcements
+// are not posted. Quiet hours are off when both are equal.
+var quietStart, quietEnd int
+
+// Set up variables, loading from environment where necessary.
+// QUIET_HOURS takes the form 23-7.
+func init() {
+	playersChannelID = os.Getenv("DISCORD_PLAYERS_CHANNEL_ID")
+
+	presenceDebounce = defaultPresenceDebounce
+	if v := os.Getenv("PRESENCE_DEBOUNCE_SECONDS"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 {
+			log.Fatal("Environment Variable PRESENCE_DEBOUNCE_SECONDS must be a number of seconds.")
+		}
+		presenceDebounce = time.Duration(seconds) * time.Second
+	}
+
+	if v := os.Getenv("QUIET_HOURS"); v != "" {
+		_, err := fmt.Sscanf(v, "%d-%d", &quietStart, &quietEnd)
+		if err != nil || quietStart < 0 || quietStart > 23 || quietEnd < 0 || quietEnd > 23 {
+			log.Fatal("Environment Variable QUIET_HOURS must look like 23-7.")
+		}
+	}
+}
+
+func isQuietHour(t time.Time) bool {
+	h := t.Hour()
+	if quietStart <= quietEnd {
+		return h >= quietStart && h < quietEnd
+	}
+	return h >= quietStart || h < quietEnd
+}
+
+// Works out who joined and left from successive player lists. Leaves are
+// held back for presenceDebounce, and dropped if the player comes back
+// within it.
+type presenceTracker struct {
+	mutex    sync.Mutex
+	online   map[string]bool
+	leaving  map[string]*time.Timer
+	announce func(player string, joined bool)
+}
+
+func newPresenceTracker(announce func(player string, joined bool)) *presenceTracker {
+	return &presenceTracker{
+		online:   make(map[string]bool),
+		leaving:  make(map[string]*time.Timer),
+		announce: announce,
+	}
+}
+
+func (p *presenceTracker) update(u server.PlayerUpdate) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	current := make(map[string]bool)
+	for _, name := range u.Players {
+		current[name] = true
+		if t, ok := p.leaving[name]; ok {
+			t.Stop()
+			delete(p.leaving, name)
+			continue
+		}
+		if !p.online[name] {
+			p.online[name] = true
+			// The first update after subscribing lists who was already
+			// online, possibly from before the bot started.
+			if !u.First {
+				go p.announce(name, true)
+			}
+		}
+	}
+
+	for name := range p.online {
+		if current[name] || p.leaving[name] != nil {
+			continue
+		}
+		name := name
+		var t *time.Timer
+		t = time.AfterFunc(presenceDebounce, func() {
+			p.mutex.Lock()
+			if p.leaving[name] != t {
+				p.mutex.Unlock()
+				return
+			}
+			delete(p.leaving, name)
+			delete(p.online, name)
+			p.mutex.Unlock()
+			p.announce(name, false)
+		})
+		p.leaving[name] = t
+	}
+}
+
+// Follows the players online on the MC server to record their playtime and
+// announce them joining and leaving.
+func WatchPlayers(s *discordgo.Session) {
+	playtime := newPlaytimeTracker()
+	go playtime.keepAlive()
+
+	var presence *presenceTracker
+	if playersChannelID != "" {
+		presence = newPresenceTracker(func(player string, joined bool) {
+			if isQuietHour(time.Now()) {
+				return
+			}
+			content := fmt.Sprintf("**%v** left the server", escapeMarkdown(player))
+			if joined {
+				content = fmt.Sprintf("**%v** joined the server :wave:", escapeMarkdown(player))
+			}
+			_, err := s.ChannelMessageSendComplex(playersChannelID, &discordgo.MessageSend{
+				Content:         content,
+				AllowedMentions: &discordgo.MessageAllowedMentions{},
+			})
+			if err != nil {
+				log.WithError(err).Error("unable to announce player")
+			}
+		})
+	}
+
+	server.WatchPlayers(func(u server.PlayerUpdate) {
+		playtime.update(u)
+		if presence != nil {
+			presence.update(u)
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+)
+
+func TestPresenceTracker(t *testing.T) {
+	defer func(d time.Duration) { presenceDebounce = d }(presenceDebounce)
+	presenceDebounce = 100 * time.Millisecond
+
+	// An update, then how long to wait before the next one.
+	type step struct {
+		players []string
+		first   bool
+		wait    time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		want  []string
+	}{
+		{
+			name:  "already online",
+			steps: []step{{players: []string{"Steve"}, first: true}},
+		},
+		{
+			name:  "join",
+			steps: []step{{first: true}, {players: []string{"Steve"}}},
+			want:  []string{"Steve joined"},
+		},
+		{
+			name: "rejoin inside the window",
+			steps: []step{
+				{players: []string{"Steve"}, first: true},
+				{wait: presenceDebounce / 4},
+				{players: []string{"Steve"}},
+			},
+		},
+		{
+			name: "leave after the window",
+			steps: []step{
+				{players: []string{"Steve"}, first: true},
+				{wait: 3 * presenceDebounce},
+				{players: []string{"Steve"}},
+			},
+			want: []string{"Steve left", "Steve joined"},
+		},
+		{
+			name: "left for good",
+			steps: []step{
+				{players: []string{"Steve", "Alex"}, first: true},
+				{players: []string{"Alex"}},
+			},
+			want: []string{"Steve left"},
+		},
+		{
+			name: "lost subscription",
+			steps: []step{
+				{players: []string{"Steve"}, first: true},
+				{},
+			},
+			want: []string{"Steve left"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			announced := make(chan string, 10)
+			p := newPresenceTracker(func(player string, joined bool) {
+				if joined {
+					announced <- fmt.Sprintf("%v joined", player)
+				} else {
+					announced <- fmt.Sprintf("%v left", player)
+				}
+			})
+
+			for _, s := range test.steps {
+				p.update(server.PlayerUpdate{Players: s.players, First: s.first})
+				time.Sleep(s.wait)
+			}
+			time.Sleep(3 * presenceDebounce)
+
+			var got []string
+			for len(announced) > 0 {
+				got = append(got, <-announced)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("announced %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsQuietHour(t *testing.T) {
+	defer func(start, end int) { quietStart, quietEnd = start, end }(quietStart, quietEnd)
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2021, 6, 1, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		name       string
+		start, end int
+		time       time.Time
+		want       bool
+	}{
+		{name: "off", start: 0, end: 0, time: at(3, 0), want: false},
+		{name: "same day, inside", start: 13, end: 15, time: at(14, 30), want: true},
+		{name: "same day, at the end", start: 13, end: 15, time: at(15, 0), want: false},
+		{name: "same day, before", start: 13, end: 15, time: at(12, 59), want: false},
+		{name: "overnight, at the start", start: 23, end: 7, time: at(23, 0), want: true},
+		{name: "overnight, after midnight", start: 23, end: 7, time: at(0, 30), want: true},
+		{name: "overnight, just before the end", start: 23, end: 7, time: at(6, 59), want: true},
+		{name: "overnight, at the end", start: 23, end: 7, time: at(7, 0), want: false},
+		{name: "overnight, evening", start: 23, end: 7, time: at(22, 59), want: false},
+		{name: "overnight, midday", start: 23, end: 7, time: at(12, 0), want: false},
+	}
+	for _, test := range tests {
+		quietStart, quietEnd = test.start, test.end
+		if got := isQuietHour(test.time); got != test.want {
+			t.Errorf("%v: isQuietHour(%v) = %v, want %v", test.name, test.time.Format("15:04"), got, test.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	log "github.com/sirupsen/logrus"
+)
+
+func serverResize(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	start := time.Now()
+
+	// Resizing changes what the server costs, as well as stopping it.
+	if !isAdmin(i) {
+		metrics.ObserveCommand("server resize", start, outcomeFailure)
+		respondEphemeral(s, i, "only admins can resize the server :no_entry:")
+		return
+	}
+
+	var machineType string
+	var confirm bool
+	var startAfter *bool
+	for _, o := range i.ApplicationCommandData().Options[0].Options {
+		switch o.Name {
+		case "machine-type":
+			machineType = o.StringValue()
+		case "confirm":
+			confirm = o.BoolValue()
+		case "start":
+			v := o.BoolValue()
+			startAfter = &v
+		}
+	}
+
+	status, err := server.InstanceStatus()
+	if errors.Is(err, server.ErrNotFound) {
+		metrics.ObserveCommand("server resize", start, outcomeFailure)
+		respond(s, i, "there's no server to resize")
+		return
+	} else if err != nil {
+		log.WithError(err).Error("unable to get the instance status")
+		metrics.ObserveCommand("server resize", start, outcomeFailure)
+		respond(s, i, "unable to check if MC server is up")
+		return
+	}
+	if server.ResizeStopsServer(status) && !confirm {
+		respondEphemeral(s, i, fmt.Sprintf("the server is %v, and resizing it means stopping it (and kicking everyone off). run the command again with `confirm: True` if you're sure", strings.ToLower(status)))
+		return
+	}
+	// By default, leave the server how we found it.
+	if startAfter == nil {
+		serverIsUp := status == "RUNNING"
+		startAfter = &serverIsUp
+	}
+
+	content := fmt.Sprintf("resizing the server to `%v`... ", machineType)
+	respond(s, i, content)
+
+	err = server.ResizeServer(machineType, *startAfter, func(phase server.Phase) {
+		content += phaseMessage(phase)
+		editResponse(s, i, content)
+	})
+
+	outcome := outcomeSuccess
+	res := "done!"
+	if errors.Is(err, server.ErrDNSUpdate) {
+		res = upMessage(server.UpStarted, err)
+	} else if err != nil {
+		outcome = outcomeFailure
+		switch {
+		case errors.Is(err, server.ErrInvalidMachineType):
+			res = fmt.Sprintf("`%v` isn't a machine type we can use here", machineType)
+		case errors.Is(err, server.ErrNotFound):
+			res = "there's no server to resize"
+		case errors.Is(err, server.ErrSuspended):
+			res = "the server is suspended, resume it with `/server up` first"
+		default:
+			log.WithError(err).Error("unable to resize the server")
+			res = "failed"
+		}
+	}
+	metrics.ObserveCommand("server resize", start, outcome)
+	auditCommand(s, i, "server resize", map[string]string{"machine-type": machineType}, start, outcome, errorDetail(err))
+	editResponse(s, i, content+res)
+
+	if *startAfter && (err == nil || errors.Is(err, server.ErrDNSUpdate)) {
+		McServerIsUp(s)
+	}
+}
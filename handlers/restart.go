@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	log "github.com/sirupsen/logrus"
+)
+
+func serverRestart(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	start := time.Now()
+	content := "restarting the server (this might take a few minutes)... "
+	respond(s, i, content)
+
+	note, res, outcome, err := countDownInResponse(s, i, content)
+	content += note
+	if res != "" {
+		metrics.ObserveCommand("server restart", start, outcome)
+		auditCommand(s, i, "server restart", nil, start, outcome, errorDetail(err))
+		editResponse(s, i, content+res)
+		return
+	}
+
+	result, err := server.RestartServer(func(phase server.Phase) {
+		// Starting means the server made it down.
+		if phase == server.PhaseStarting {
+			recordUptime(i, "down")
+		}
+		content += phaseMessage(phase)
+		editResponse(s, i, content)
+	})
+
+	if err != nil && !errors.Is(err, server.ErrDNSUpdate) {
+		outcome = outcomeFailure
+		switch {
+		case errors.Is(err, server.ErrNotFound):
+			res = "there's no server to restart, use `/server up` to create one"
+		case errors.Is(err, server.ErrSuspended):
+			res = "the server is suspended, use `/server up` to resume it"
+		default:
+			log.WithError(err).Error("unable to restart the server")
+			res = "failed"
+		}
+	} else {
+		res = upMessage(result, err)
+		recordUptime(i, "up")
+	}
+	metrics.ObserveCommand("server restart", start, outcome)
+	auditCommand(s, i, "server restart", nil, start, outcome, errorDetail(err))
+	editResponse(s, i, content+res)
+	McServerIsUp(s)
+}
+
+func serverReset(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	start := time.Now()
+
+	if !isAdmin(i) {
+		respondEphemeral(s, i, "only admins can reset the server :no_entry:")
+		return
+	}
+	var confirm bool
+	for _, o := range i.ApplicationCommandData().Options[0].Options {
+		if o.Name == "confirm" {
+			confirm = o.BoolValue()
+		}
+	}
+	if !confirm {
+		respondEphemeral(s, i, "resetting is like pulling the plug: anything Minecraft hasn't saved is lost. run the command again with `confirm: True` if the server is really frozen")
+		return
+	}
+
+	content := "resetting the server... "
+	respond(s, i, content)
+
+	err := server.ResetServer(func(phase server.Phase) {
+		content += phaseMessage(phase)
+		editResponse(s, i, content)
+	})
+
+	outcome := outcomeSuccess
+	res := fmt.Sprintf("done! Minecraft should be back @ %v in a few minutes", server.ManagementServerAddress)
+	if err != nil {
+		outcome = outcomeFailure
+		switch {
+		case errors.Is(err, server.ErrNotFound):
+			res = "there's no server to reset"
+		case errors.Is(err, server.ErrNotRunning):
+			res = "the server isn't running, so there's nothing to reset. try `/server up`"
+		default:
+			log.WithError(err).Error("unable to reset the server")
+			res = "failed"
+		}
+	}
+	metrics.ObserveCommand("server reset", start, outcome)
+	auditCommand(s, i, "server reset", nil, start, outcome, errorDetail(err))
+	editResponse(s, i, content+res)
+}
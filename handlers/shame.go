@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	"github.com/mirrorkeydev/discord-mc-bot/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultShameCooldown = 10 * time.Minute
+	maxShameReasonLength = 500
+	shameHistoryLength   = 10
+)
+
+// How long a user has to wait between shamings.
+var shameCooldown time.Duration
+
+// Set up variables, loading from environment where necessary
+func init() {
+	shameCooldown = defaultShameCooldown
+	if v := os.Getenv("SHAME_COOLDOWN_SECONDS"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 {
+			log.Fatal("Environment Variable SHAME_COOLDOWN_SECONDS must be a number of seconds.")
+		}
+		shameCooldown = time.Duration(seconds) * time.Second
+	}
+}
+
+func Shame(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	switch i.ApplicationCommandData().Options[0].Name {
+	case "add":
+		shameAdd(s, i)
+	case "history":
+		shameHistory(s, i)
+	case "leaderboard":
+		shameLeaderboard(s, i)
+	}
+}
+
+func shameAdd(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	start := time.Now()
+	var target *discordgo.User
+	var reason string
+	for _, o := range i.ApplicationCommandData().Options[0].Options {
+		switch o.Name {
+		case "user":
+			target = o.UserValue(s)
+		case "message":
+			reason = o.StringValue()
+		}
+	}
+	shamer := invoker(i)
+
+	var refusal string
+	switch {
+	case target == nil || shamer == nil:
+		refusal = "something has gone wrong, I don't know who's shaming who"
+	case target.Bot:
+		refusal = "bots are beyond shame :robot:"
+	case target.ID == shamer.ID:
+		refusal = "you can't shame yourself, that's just sad"
+	}
+	if refusal != "" {
+		metrics.ObserveCommand("shame add", start, outcomeFailure)
+		respondEphemeral(s, i, refusal)
+		return
+	}
+
+	shames, err := storage.AllShames(store)
+	if err != nil {
+		log.WithError(err).Error("unable to read the shame ledger")
+		metrics.ObserveCommand("shame add", start, outcomeFailure)
+		respondEphemeral(s, i, "failed")
+		return
+	}
+	for n := len(shames) - 1; n >= 0; n-- {
+		if shames[n].ShamerID != shamer.ID {
+			continue
+		}
+		if wait := shameCooldown - time.Since(shames[n].Time); wait > 0 {
+			metrics.ObserveCommand("shame add", start, outcomeFailure)
+			respondEphemeral(s, i, fmt.Sprintf("you're shaming too much, try again in %v", wait.Round(time.Second)))
+			return
+		}
+		break
+	}
+
+	reason = truncate(sanitizeMentions(strings.TrimSpace(reason)), maxShameReasonLength)
+	err = storage.AddShame(store, storage.Shame{
+		Time:     start,
+		ShamerID: shamer.ID,
+		TargetID: target.ID,
+		Reason:   reason,
+	})
+	if err != nil {
+		// Posting it anyway would leave the history and leaderboard
+		// disagreeing with the channel.
+		log.WithError(err).Error("unable to record shame")
+		metrics.ObserveCommand("shame add", start, outcomeFailure)
+		respondEphemeral(s, i, "couldn't record that shame, try again later")
+		return
+	}
+
+	metrics.ObserveCommand("shame add", start, outcomeSuccess)
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("%v, you have been shamed: %v", target.Mention(), reason),
+			// Only the target gets pinged, whatever the reason says.
+			AllowedMentions: &discordgo.MessageAllowedMentions{Users: []string{target.ID}},
+		},
+	})
+}
+
+func shameHistory(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	start := time.Now()
+	target := i.ApplicationCommandData().Options[0].Options[0].UserValue(s)
+
+	shames, err := storage.AllShames(store)
+	if err != nil {
+		log.WithError(err).Error("unable to read the shame ledger")
+		metrics.ObserveCommand("shame history", start, outcomeFailure)
+		respond(s, i, "unable to read the shame ledger")
+		return
+	}
+	metrics.ObserveCommand("shame history", start, outcomeSuccess)
+
+	var theirs []storage.Shame
+	for _, sh := range shames {
+		if sh.TargetID == target.ID {
+			theirs = append(theirs, sh)
+		}
+	}
+	if len(theirs) == 0 {
+		respondWithoutMentions(s, i, fmt.Sprintf("%v has never been shamed :innocent:", target.Mention()))
+		return
+	}
+
+	lines := []string{fmt.Sprintf("%v has been shamed %v times, most recently:", target.Mention(), len(theirs))}
+	for n := len(theirs) - 1; n >= 0 && n >= len(theirs)-shameHistoryLength; n-- {
+		sh := theirs[n]
+		lines = append(lines, fmt.Sprintf("%v by <@%v>: %v", sh.Time.Format("2006-01-02"), sh.ShamerID, sh.Reason))
+	}
+	respondWithoutMentions(s, i, truncate(strings.Join(lines, "\n"), maxMessageLength))
+}
+
+func shameLeaderboard(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	start := time.Now()
+
+	shames, err := storage.AllShames(store)
+	if err != nil {
+		log.WithError(err).Error("unable to read the shame ledger")
+		metrics.ObserveCommand("shame leaderboard", start, outcomeFailure)
+		respond(s, i, "unable to read the shame ledger")
+		return
+	}
+	metrics.ObserveCommand("shame leaderboard", start, outcomeSuccess)
+	if len(shames) == 0 {
+		respond(s, i, "nobody has been shamed yet :innocent:")
+		return
+	}
+
+	counts := make(map[string]int)
+	var targets []string
+	for _, sh := range shames {
+		if counts[sh.TargetID] == 0 {
+			targets = append(targets, sh.TargetID)
+		}
+		counts[sh.TargetID]++
+	}
+	sort.SliceStable(targets, func(a, b int) bool { return counts[targets[a]] > counts[targets[b]] })
+	if len(targets) > leaderboardSize {
+		targets = targets[:leaderboardSize]
+	}
+
+	lines := []string{"most shamed:"}
+	for n, t := range targets {
+		lines = append(lines, fmt.Sprintf("%v. <@%v> %v", n+1, t, counts[t]))
+	}
+	respondWithoutMentions(s, i, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	log "github.com/sirupsen/logrus"
+)
+
+func serverStatus(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	start := time.Now()
+	respond(s, i, "checking... ")
+
+	status, err := server.CurrentStatus()
+	if err != nil {
+		log.WithError(err).Error("unable to check the server status")
+		metrics.ObserveCommand("server status", start, outcomeFailure)
+		editResponse(s, i, "unable to check the server status")
+		return
+	}
+	metrics.ObserveCommand("server status", start, outcomeSuccess)
+	editResponse(s, i, statusMessage(status))
+}
+
+func statusMessage(status server.Status) string {
+	lines := []string{fmt.Sprintf("%v VM: %v", statusEmoji(status.VMUp()), strings.ToLower(status.VM))}
+	if !status.VMUp() {
+		return strings.Join(lines, "\n")
+	}
+
+	if status.Management {
+		lines = append(lines, statusEmoji(true)+" management server: reachable")
+	} else {
+		lines = append(lines, statusEmoji(false)+" management server: unreachable")
+	}
+
+	mc := status.Minecraft
+	if mc == nil {
+		lines = append(lines, statusEmoji(false)+" Minecraft: not accepting connections yet")
+		return strings.Join(lines, "\n")
+	}
+	players := fmt.Sprintf("%v/%v players", mc.Online, mc.Max)
+	if len(mc.Players) > 0 {
+		players += " (" + escapeMarkdown(strings.Join(mc.Players, ", ")) + ")"
+	}
+	lines = append(lines, fmt.Sprintf("%v Minecraft: joinable @ %v, %v, %v, %vms",
+		statusEmoji(true), server.ManagementServerAddress, mc.Version, players, mc.Latency.Milliseconds()))
+	if mc.MOTD != "" {
+		lines = append(lines, "> "+sanitizeMentions(escapeMarkdown(mc.MOTD)))
+	}
+	return truncate(strings.Join(lines, "\n"), maxMessageLength)
+}
+
+func statusEmoji(ok bool) string {
+	if ok {
+		return ":green_circle:"
+	}
+	return ":red_circle:"
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mirrorkeydev/discord-mc-bot/audit"
+	"github.com/mirrorkeydev/discord-mc-bot/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// Discord flag marking an interaction response as only visible to the invoker.
+const ephemeralFlag = 1 << 6
+
+// Maximum length of a Discord message.
+const maxMessageLength = 2000
+
+var adminRoleID string
+var adminChannelID string
+var statusChannelID string
+
+// Persistent bot state. Defaults to memory until main provides a database.
+var store storage.Store = storage.NewMemoryStore()
+
+// Set up variables, loading from environment where necessary
+func init() {
+	adminRoleID = os.Getenv("DISCORD_ADMIN_ROLE_ID")
+	adminChannelID = os.Getenv("DISCORD_ADMIN_CHANNEL_ID")
+	statusChannelID = os.Getenv("DISCORD_STATUS_CHANNEL_ID")
+}
+
+// Sets the store used to persist bot state across restarts.
+func SetStore(s storage.Store) {
+	store = s
+}
+
+// Returns the user who invoked an interaction, whether it came from a
+// guild or a DM.
+func invoker(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// Admins either hold the configured admin role or have the Administrator
+// permission in the guild.
+func isAdmin(i *discordgo.InteractionCreate) bool {
+	if i.Member == nil {
+		return false
+	}
+	if i.Member.Permissions&discordgo.PermissionAdministrator != 0 {
+		return true
+	}
+	for _, r := range i.Member.Roles {
+		if adminRoleID != "" && r == adminRoleID {
+			return true
+		}
+	}
+	return false
+}
+
+func respond(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+		},
+	})
+}
+
+// Replaces the content of an earlier response, e.g. once a long running
+// action has finished. Any buttons on it are removed.
+func editResponse(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	editResponseWithComponents(s, i, content, []discordgo.MessageComponent{})
+}
+
+// Like editResponse, but sets the buttons shown under the response.
+func editResponseWithComponents(s *discordgo.Session, i *discordgo.InteractionCreate, content string, components []discordgo.MessageComponent) {
+	_, err := s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
+		Content:    content,
+		Components: components,
+	})
+	if err != nil {
+		s.FollowupMessageCreate(s.State.User.ID, i.Interaction, true, &discordgo.WebhookParams{
+			Content: "something went wrong",
+		})
+	}
+}
+
+// Responds with content that mentions users, without pinging any of them.
+func respondWithoutMentions(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:         content,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+}
+
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   ephemeralFlag,
+		},
+	})
+}
+
+// Records a privileged command in the audit log.
+func auditCommand(s *discordgo.Session, i *discordgo.InteractionCreate, action string, args map[string]string, start time.Time, outcome string, detail string) {
+	e := audit.Entry{
+		Time:       start,
+		Action:     action,
+		Args:       args,
+		Outcome:    outcome,
+		Detail:     detail,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if u := invoker(i); u != nil {
+		e.UserID = u.ID
+		e.Username = u.String()
+	}
+	audit.Record(s, e)
+}
+
+// Cuts s down to at most n bytes, marking that it was cut. The cut never
+// splits a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := n - 3
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "..."
+}
+
+func errorDetail(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Records a server state change made by the invoker in the uptime history.
+func recordUptime(i *discordgo.InteractionCreate, status string) {
+	e := storage.UptimeEvent{Time: time.Now(), Status: status}
+	if u := invoker(i); u != nil {
+		e.UserID = u.ID
+	}
+	err := storage.AddUptimeEvent(store, e)
+	if err != nil {
+		log.WithError(err).Error("unable to record uptime event")
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{s: "short", n: 10, want: "short"},
+		{s: "exactly10!", n: 10, want: "exactly10!"},
+		{s: "a bit too long", n: 10, want: "a bit t..."},
+		// "é" is two bytes; cutting at 7 would split the second one.
+		{s: "ééééé", n: 8, want: "éé..."},
+		// "🙂" is four bytes.
+		{s: "a🙂🙂", n: 8, want: "a🙂..."},
+		{s: "a🙂🙂", n: 7, want: "a..."},
+	}
+	for _, test := range tests {
+		got := truncate(test.s, test.n)
+		if got != test.want {
+			t.Errorf("truncate(%q, %v) = %q, want %q", test.s, test.n, got, test.want)
+		}
+		if len(got) > test.n || !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %v) = %q is too long or invalid UTF-8", test.s, test.n, got)
+		}
+	}
+}
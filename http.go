@@ -0,0 +1,113 @@
+// HTTP endpoints used to monitor the bot.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mirrorkeydev/discord-mc-bot/handlers"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+var httpListenAddress string
+
+func init() {
+	httpListenAddress = os.Getenv("HTTP_LISTEN_ADDRESS")
+	if httpListenAddress == "" {
+		httpListenAddress = ":8080"
+	}
+}
+
+func startHTTPServer() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", healthz)
+	mux.HandleFunc("/readyz", readyz)
+
+	go func() {
+		log.Infof("Serving HTTP endpoints on %v", httpListenAddress)
+		err := http.ListenAndServe(httpListenAddress, mux)
+		if err != nil {
+			log.WithError(err).Error("HTTP server stopped")
+		}
+	}()
+}
+
+type healthReport struct {
+	DiscordConnected      bool       `json:"discord_connected"`
+	ComputeReady          bool       `json:"compute_ready"`
+	LastGCPSuccess        *time.Time `json:"last_gcp_success,omitempty"`
+	ManagementServerState string     `json:"management_server_state"`
+}
+
+func currentHealth() healthReport {
+	discordSession.RLock()
+	discordConnected := discordSession.DataReady
+	discordSession.RUnlock()
+
+	report := healthReport{
+		DiscordConnected:      discordConnected,
+		ComputeReady:          server.ComputeReady(),
+		ManagementServerState: server.ManagementServerState().String(),
+	}
+	if t := server.LastGCPSuccess(); !t.IsZero() {
+		report.LastGCPSuccess = &t
+	}
+	return report
+}
+
+func writeHealth(w http.ResponseWriter, code int, report healthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(report)
+	if err != nil {
+		log.WithError(err).Error("unable to write health report")
+	}
+}
+
+// Liveness: the process is up and serving requests.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, currentHealth())
+}
+
+// Readiness: the bot is connected to Discord and can talk to GCP. The
+// management server is reported but not required, since it is only up
+// while the MC server is.
+func readyz(w http.ResponseWriter, r *http.Request) {
+	report := currentHealth()
+	code := http.StatusOK
+	if !report.DiscordConnected || !report.ComputeReady {
+		code = http.StatusServiceUnavailable
+	}
+	writeHealth(w, code, report)
+}
+
+// Periodically refreshes the server status so that metrics stay current
+// even when nobody is running commands. The player count is kept current
+// by the management server's subscription, and the bot's status is only
+// sent to Discord when it changes.
+func pollServerStatus() {
+	for range time.Tick(time.Minute) {
+		serverIsUp, err := server.IsUp()
+		if err != nil {
+			continue
+		}
+		handlers.UpdatePresence(discordSession, serverIsUp)
+		if !serverIsUp {
+			metrics.PlayerCount.Set(0)
+			metrics.MinecraftJoinable.Set(0)
+			continue
+		}
+		_, err = server.PingMinecraft(context.Background())
+		if err != nil {
+			log.WithError(err).Debug("MC server isn't answering pings")
+		}
+	}
+}
@@ -8,11 +8,14 @@ import (
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/mirrorkeydev/discord-mc-bot/handlers"
+	"github.com/mirrorkeydev/discord-mc-bot/server"
+	"github.com/mirrorkeydev/discord-mc-bot/storage"
 )
 
 var discordBotToken string
 var discordGuildID string
 var discordSession *discordgo.Session
+var botStore *storage.BoltStore
 
 func init() {
 	discordBotToken = os.Getenv("DISCORD_BOT_TOKEN")
@@ -24,19 +27,50 @@ func init() {
 		log.Fatal("Environment Variable DISCORD_GUILD_ID not set.")
 	}
 
+	storagePath := os.Getenv("STORAGE_PATH")
+	if storagePath == "" {
+		storagePath = "bot.db"
+	}
+
 	var err error
+	botStore, err = storage.Open(storagePath)
+	if err != nil {
+		log.WithError(err).Fatal("cannot open bot storage")
+	}
+	handlers.SetStore(botStore)
+
+	err = server.ConnectCompute()
+	if err != nil {
+		log.WithError(err).Fatal("cannot connect to GCP compute")
+	}
+
 	discordSession, err = discordgo.New("Bot " + discordBotToken)
 	if err != nil {
 		log.WithError(err).Fatal("invalid discord bot parameters")
 	}
 
 	discordSession.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		if h, ok := commandHandlers[i.Data.Name]; ok {
-			h(s, i)
+		switch i.Type {
+		case discordgo.InteractionApplicationCommand:
+			if h, ok := commandHandlers[i.ApplicationCommandData().Name]; ok {
+				h(s, i)
+			}
+		case discordgo.InteractionMessageComponent:
+			if h, ok := componentHandlers[i.MessageComponentData().CustomID]; ok {
+				h(s, i)
+			}
 		}
 	})
+	discordSession.AddHandler(handlers.RelayToMinecraft)
+	// Message content is a privileged intent, so it also has to be enabled
+	// for the bot in the Discord developer portal. Without it the chat
+	// bridge receives every message empty.
+	discordSession.Identify.Intents = discordgo.IntentsAllWithoutPrivileged | discordgo.IntentMessageContent
 }
 
+// Option bounds need an address.
+var auditMinCount = 1.0
+
 var commands = []*discordgo.ApplicationCommand{
 	{
 		Name:        "ping",
@@ -50,6 +84,11 @@ var commands = []*discordgo.ApplicationCommand{
 		Name:        "server",
 		Description: "Control the Minecraft Server",
 		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "status",
+				Description: "Check whether the server is up and joinable",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
 			{
 				Name:        "up",
 				Description: "Bring the server up",
@@ -60,6 +99,59 @@ var commands = []*discordgo.ApplicationCommand{
 				Description: "Bring the server down",
 				Type:        discordgo.ApplicationCommandOptionSubCommand,
 			},
+			{
+				Name:        "cancel",
+				Description: "Cancel a shutdown that is counting down",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        "suspend",
+				Description: "Suspend the server, keeping its memory for a quick resume with /server up",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        "restart",
+				Description: "Stop the server and start it again",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        "reset",
+				Description: "Hard reset a frozen server (admins only)",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+						Name:        "confirm",
+						Description: "Confirm that unsaved progress may be lost",
+						Required:    false,
+					},
+				},
+			},
+			{
+				Name:        "resize",
+				Description: "Change the server's machine type (admins only)",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "machine-type",
+						Description: "The GCP machine type to switch to, e.g. e2-standard-4",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+						Name:        "confirm",
+						Description: "Confirm that the server may be stopped if it is up",
+						Required:    false,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+						Name:        "start",
+						Description: "Start the server after resizing (defaults to whether it was up)",
+						Required:    false,
+					},
+				},
+			},
 		},
 	},
 	{
@@ -76,30 +168,225 @@ var commands = []*discordgo.ApplicationCommand{
 	},
 	{
 		Name:        "shame",
-		Description: "Shame a user",
+		Description: "Shame a user, and look back on past shame",
 		Options: []*discordgo.ApplicationCommandOption{
 			{
-				Type:        discordgo.ApplicationCommandOptionUser,
-				Name:        "user",
-				Description: "The user to shame",
+				Name:        "add",
+				Description: "Shame a user",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionUser,
+						Name:        "user",
+						Description: "The user to shame",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "message",
+						Description: "The message you want to relay to the user",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Name:        "history",
+				Description: "Show how a user has been shamed",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionUser,
+						Name:        "user",
+						Description: "The user to look up",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Name:        "leaderboard",
+				Description: "Show who has been shamed the most",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+		},
+	},
+	{
+		Name:        "audit",
+		Description: "Read the audit log of privileged actions (admins only)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "recent",
+				Description: "Show the most recent audited actions",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "count",
+						Description: "How many entries to show (default 10, max 25)",
+						Required:    false,
+						MinValue:    &auditMinCount,
+						MaxValue:    25,
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        "admin",
+		Description: "Bot administration (admins only)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "certs",
+				Description: "Show when the management server certificates expire",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+		},
+	},
+	{
+		Name:        "backup",
+		Description: "Back up or restore the Minecraft Server's disk",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "create",
+				Description: "Snapshot the server's disk (admins only)",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        "list",
+				Description: "List the most recent backups",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        "restore",
+				Description: "Replace the server's disk with a backup (admins only, server must be down)",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "name",
+						Description: "The name of the backup to restore",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+						Name:        "confirm",
+						Description: "Confirm that the current disk should be replaced",
+						Required:    false,
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        "link",
+		Description: "Link your Discord account to your Minecraft username",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "minecraft-name",
+				Description: "Your Minecraft username",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        "console",
+		Description: "Run a command on the Minecraft server console (admins only)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "command",
+				Description: "The command to run, e.g. time set day",
 				Required:    true,
 			},
+		},
+	},
+	{
+		Name:        "leaderboard",
+		Description: "Show who's top of the server",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "playtime",
+				Description: "Show who has played the most",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "period",
+						Description: "How far back to look (default all)",
+						Required:    false,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "week", Value: "week"},
+							{Name: "month", Value: "month"},
+							{Name: "all", Value: "all"},
+						},
+					},
+				},
+			},
+			{
+				Name:        "deaths",
+				Description: "Show who has died the most",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "period",
+						Description: "How far back to look (default all)",
+						Required:    false,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "week", Value: "week"},
+							{Name: "month", Value: "month"},
+							{Name: "all", Value: "all"},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        "playtime",
+		Description: "Show how long a player has played",
+		Options: []*discordgo.ApplicationCommandOption{
 			{
 				Type:        discordgo.ApplicationCommandOptionString,
-				Name:        "message",
-				Description: "The message you want to relay to the user",
+				Name:        "player",
+				Description: "The player's Minecraft username",
 				Required:    true,
 			},
 		},
 	},
+	{
+		Name:        "deaths",
+		Description: "Show death statistics",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "player",
+				Description: "Only show this player's deaths",
+				Required:    false,
+			},
+		},
+	},
 }
 
 var commandHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
-	"ping": handlers.Ping,
-	"version": handlers.Version,
-	"server": handlers.Server,
-	"whitelist": handlers.Whitelist,
-	"shame": handlers.Shame,
+	"ping":        handlers.Ping,
+	"version":     handlers.Version,
+	"server":      handlers.Server,
+	"whitelist":   handlers.Whitelist,
+	"shame":       handlers.Shame,
+	"audit":       handlers.Audit,
+	"admin":       handlers.Admin,
+	"backup":      handlers.Backup,
+	"link":        handlers.Link,
+	"console":     handlers.Console,
+	"leaderboard": handlers.Leaderboard,
+	"playtime":    handlers.Playtime,
+	"deaths":      handlers.Deaths,
+}
+
+// Handlers for buttons on the bot's messages, by custom ID.
+var componentHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
+	handlers.CancelShutdownID: handlers.CancelShutdown,
 }
 
 func setUpCommands() {
@@ -151,9 +438,20 @@ func main() {
 	setUpCommands()
 
 	// To initialize status
-	handlers.McServerIsUp(discordSession);
+	handlers.McServerIsUp(discordSession)
+	go pollServerStatus()
+	go server.WatchCerts()
+	go handlers.WatchCertExpiry(discordSession)
+	go server.ScheduleBackups()
+	go handlers.WatchPreemptions(discordSession)
+	go handlers.BridgeChat(discordSession)
+	go handlers.WatchPlayers(discordSession)
+	go handlers.WatchDeaths(discordSession)
+
+	startHTTPServer()
 
 	defer discordSession.Close()
+	defer botStore.Close()
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt)
@@ -0,0 +1,142 @@
+// Prometheus metrics for the bot and the MC server lifecycle.
+// Collectors are registered with the default registry and exposed
+// by the HTTP server started in main.
+
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	CommandInvocations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mcbot_command_invocations_total",
+		Help: "Slash command invocations by command and outcome.",
+	}, []string{"command", "outcome"})
+
+	CommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mcbot_command_duration_seconds",
+		Help:    "Time taken to handle a slash command.",
+		Buckets: []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300},
+	}, []string{"command"})
+
+	GCPOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mcbot_gcp_operation_duration_seconds",
+		Help:    "Time spent waiting on GCP compute operations, by operation type and outcome.",
+		Buckets: []float64{1, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"operation", "outcome"})
+
+	GRPCCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mcbot_grpc_call_duration_seconds",
+		Help:    "Latency of calls to the MC management server, by method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	GRPCCallErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mcbot_grpc_call_errors_total",
+		Help: "Failed calls to the MC management server, by method and status code.",
+	}, []string{"method", "code"})
+
+	VMStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mcbot_vm_status",
+		Help: "Current status of the GCP instance; the active status is set to 1.",
+	}, []string{"status"})
+
+	PlayerCount = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mcbot_player_count",
+		Help: "Number of players online, as last reported by the management server.",
+	})
+
+	CertExpiryDays = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mcbot_cert_expiry_days",
+		Help: "Days until the management server certificates expire.",
+	}, []string{"cert"})
+
+	MinecraftJoinable = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mcbot_minecraft_joinable",
+		Help: "Whether the MC server answered the last server list ping.",
+	})
+
+	MinecraftLatency = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mcbot_minecraft_ping_seconds",
+		Help: "Round trip time of the last server list ping.",
+	})
+)
+
+// All statuses a GCP compute instance can report.
+var vmStatuses = []string{
+	"PROVISIONING", "STAGING", "RUNNING", "STOPPING", "STOPPED",
+	"SUSPENDING", "SUSPENDED", "REPAIRING", "TERMINATED", "DEPROVISIONING",
+	"NOT_FOUND",
+}
+
+func init() {
+	prometheus.MustRegister(
+		CommandInvocations,
+		CommandDuration,
+		GCPOperationDuration,
+		GRPCCallDuration,
+		GRPCCallErrors,
+		VMStatus,
+		PlayerCount,
+		CertExpiryDays,
+		MinecraftJoinable,
+		MinecraftLatency,
+	)
+}
+
+// Records a finished slash command.
+func ObserveCommand(command string, start time.Time, outcome string) {
+	CommandInvocations.WithLabelValues(command, outcome).Inc()
+	CommandDuration.WithLabelValues(command).Observe(time.Since(start).Seconds())
+}
+
+// Records a finished GCP compute operation.
+func ObserveGCPOperation(operation string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	GCPOperationDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+// Marks the given status as the current status of the GCP instance.
+func SetVMStatus(current string) {
+	for _, s := range vmStatuses {
+		if s == current {
+			VMStatus.WithLabelValues(s).Set(1)
+		} else {
+			VMStatus.WithLabelValues(s).Set(0)
+		}
+	}
+}
+
+func observeGRPCCall(method string, start time.Time, err error) {
+	code := status.Code(err).String()
+	GRPCCallDuration.WithLabelValues(method, code).Observe(time.Since(start).Seconds())
+	if err != nil {
+		GRPCCallErrors.WithLabelValues(method, code).Inc()
+	}
+}
+
+// Client interceptor recording latency and errors of unary management server calls.
+func UnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	observeGRPCCall(method, start, err)
+	return err
+}
+
+// Client interceptor recording how long it takes to open a stream to the
+// management server, and whether opening it failed.
+func StreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	start := time.Now()
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	observeGRPCCall(method, start, err)
+	return stream, err
+}
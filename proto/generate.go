@@ -0,0 +1,7 @@
+// Package proto holds the client for the management server, generated from
+// the API definition in the discord-mc-protobuf submodule. StopServer,
+// BroadcastMessage, SendChatMessage, SubscribeChat and RunCommand aren't in
+// the published definition yet, so don't regenerate until they land there.
+package proto
+
+//go:generate protoc -I .. --go_out=. --go_opt=module=github.com/mirrorkeydev/discord-mc-bot/discord-mc-protobuf/proto --go-grpc_out=. --go-grpc_opt=module=github.com/mirrorkeydev/discord-mc-bot/discord-mc-protobuf/proto ../discord-mc-protobuf/proto/mc-management.proto
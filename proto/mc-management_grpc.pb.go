@@ -25,6 +25,16 @@ type MCManagementClient interface {
 	GetResourceConsumption(ctx context.Context, in *GetResourceConsumptionRequest, opts ...grpc.CallOption) (*GetResourceConsumptionResponse, error)
 	SubscribeResourceConsumptionEvent(ctx context.Context, in *SubscribeResourceConsumptionEventRequest, opts ...grpc.CallOption) (MCManagement_SubscribeResourceConsumptionEventClient, error)
 	SubscribeHeartbeat(ctx context.Context, in *SubscribeHeartbeatRequest, opts ...grpc.CallOption) (MCManagement_SubscribeHeartbeatClient, error)
+	// Asks the MC server to exit, saving the world first if requested. The
+	// heartbeat reports SIGNAL_STOP once it has.
+	StopServer(ctx context.Context, in *StopServerRequest, opts ...grpc.CallOption) (*StopServerResponse, error)
+	// Shows a message to every player, as the server.
+	BroadcastMessage(ctx context.Context, in *BroadcastMessageRequest, opts ...grpc.CallOption) (*BroadcastMessageResponse, error)
+	// Posts a message in the in-game chat on behalf of someone outside it.
+	SendChatMessage(ctx context.Context, in *SendChatMessageRequest, opts ...grpc.CallOption) (*SendChatMessageResponse, error)
+	SubscribeChat(ctx context.Context, in *SubscribeChatRequest, opts ...grpc.CallOption) (MCManagement_SubscribeChatClient, error)
+	// Runs a console command, returning its output.
+	RunCommand(ctx context.Context, in *RunCommandRequest, opts ...grpc.CallOption) (*RunCommandResponse, error)
 }
 
 type mCManagementClient struct {
@@ -190,6 +200,74 @@ func (x *mCManagementSubscribeHeartbeatClient) Recv() (*SubscribeHeartbeatRespon
 	return m, nil
 }
 
+func (c *mCManagementClient) StopServer(ctx context.Context, in *StopServerRequest, opts ...grpc.CallOption) (*StopServerResponse, error) {
+	out := new(StopServerResponse)
+	err := c.cc.Invoke(ctx, "/MCManagement/StopServer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mCManagementClient) BroadcastMessage(ctx context.Context, in *BroadcastMessageRequest, opts ...grpc.CallOption) (*BroadcastMessageResponse, error) {
+	out := new(BroadcastMessageResponse)
+	err := c.cc.Invoke(ctx, "/MCManagement/BroadcastMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mCManagementClient) SendChatMessage(ctx context.Context, in *SendChatMessageRequest, opts ...grpc.CallOption) (*SendChatMessageResponse, error) {
+	out := new(SendChatMessageResponse)
+	err := c.cc.Invoke(ctx, "/MCManagement/SendChatMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mCManagementClient) SubscribeChat(ctx context.Context, in *SubscribeChatRequest, opts ...grpc.CallOption) (MCManagement_SubscribeChatClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MCManagement_ServiceDesc.Streams[4], "/MCManagement/SubscribeChat", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &mCManagementSubscribeChatClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MCManagement_SubscribeChatClient interface {
+	Recv() (*SubscribeChatResponse, error)
+	grpc.ClientStream
+}
+
+type mCManagementSubscribeChatClient struct {
+	grpc.ClientStream
+}
+
+func (x *mCManagementSubscribeChatClient) Recv() (*SubscribeChatResponse, error) {
+	m := new(SubscribeChatResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *mCManagementClient) RunCommand(ctx context.Context, in *RunCommandRequest, opts ...grpc.CallOption) (*RunCommandResponse, error) {
+	out := new(RunCommandResponse)
+	err := c.cc.Invoke(ctx, "/MCManagement/RunCommand", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MCManagementServer is the server API for MCManagement service.
 // All implementations must embed UnimplementedMCManagementServer
 // for forward compatibility
@@ -201,6 +279,16 @@ type MCManagementServer interface {
 	GetResourceConsumption(context.Context, *GetResourceConsumptionRequest) (*GetResourceConsumptionResponse, error)
 	SubscribeResourceConsumptionEvent(*SubscribeResourceConsumptionEventRequest, MCManagement_SubscribeResourceConsumptionEventServer) error
 	SubscribeHeartbeat(*SubscribeHeartbeatRequest, MCManagement_SubscribeHeartbeatServer) error
+	// Asks the MC server to exit, saving the world first if requested. The
+	// heartbeat reports SIGNAL_STOP once it has.
+	StopServer(context.Context, *StopServerRequest) (*StopServerResponse, error)
+	// Shows a message to every player, as the server.
+	BroadcastMessage(context.Context, *BroadcastMessageRequest) (*BroadcastMessageResponse, error)
+	// Posts a message in the in-game chat on behalf of someone outside it.
+	SendChatMessage(context.Context, *SendChatMessageRequest) (*SendChatMessageResponse, error)
+	SubscribeChat(*SubscribeChatRequest, MCManagement_SubscribeChatServer) error
+	// Runs a console command, returning its output.
+	RunCommand(context.Context, *RunCommandRequest) (*RunCommandResponse, error)
 	mustEmbedUnimplementedMCManagementServer()
 }
 
@@ -229,6 +317,21 @@ func (UnimplementedMCManagementServer) SubscribeResourceConsumptionEvent(*Subscr
 func (UnimplementedMCManagementServer) SubscribeHeartbeat(*SubscribeHeartbeatRequest, MCManagement_SubscribeHeartbeatServer) error {
 	return status.Errorf(codes.Unimplemented, "method SubscribeHeartbeat not implemented")
 }
+func (UnimplementedMCManagementServer) StopServer(context.Context, *StopServerRequest) (*StopServerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopServer not implemented")
+}
+func (UnimplementedMCManagementServer) BroadcastMessage(context.Context, *BroadcastMessageRequest) (*BroadcastMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BroadcastMessage not implemented")
+}
+func (UnimplementedMCManagementServer) SendChatMessage(context.Context, *SendChatMessageRequest) (*SendChatMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendChatMessage not implemented")
+}
+func (UnimplementedMCManagementServer) SubscribeChat(*SubscribeChatRequest, MCManagement_SubscribeChatServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeChat not implemented")
+}
+func (UnimplementedMCManagementServer) RunCommand(context.Context, *RunCommandRequest) (*RunCommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RunCommand not implemented")
+}
 func (UnimplementedMCManagementServer) mustEmbedUnimplementedMCManagementServer() {}
 
 // UnsafeMCManagementServer may be embedded to opt out of forward compatibility for this service.
@@ -380,6 +483,99 @@ func (x *mCManagementSubscribeHeartbeatServer) Send(m *SubscribeHeartbeatRespons
 	return x.ServerStream.SendMsg(m)
 }
 
+func _MCManagement_StopServer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopServerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MCManagementServer).StopServer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/MCManagement/StopServer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MCManagementServer).StopServer(ctx, req.(*StopServerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MCManagement_BroadcastMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BroadcastMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MCManagementServer).BroadcastMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/MCManagement/BroadcastMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MCManagementServer).BroadcastMessage(ctx, req.(*BroadcastMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MCManagement_SendChatMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendChatMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MCManagementServer).SendChatMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/MCManagement/SendChatMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MCManagementServer).SendChatMessage(ctx, req.(*SendChatMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MCManagement_SubscribeChat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeChatRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MCManagementServer).SubscribeChat(m, &mCManagementSubscribeChatServer{stream})
+}
+
+type MCManagement_SubscribeChatServer interface {
+	Send(*SubscribeChatResponse) error
+	grpc.ServerStream
+}
+
+type mCManagementSubscribeChatServer struct {
+	grpc.ServerStream
+}
+
+func (x *mCManagementSubscribeChatServer) Send(m *SubscribeChatResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _MCManagement_RunCommand_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunCommandRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MCManagementServer).RunCommand(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/MCManagement/RunCommand",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MCManagementServer).RunCommand(ctx, req.(*RunCommandRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // MCManagement_ServiceDesc is the grpc.ServiceDesc for MCManagement service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -399,6 +595,22 @@ var MCManagement_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetResourceConsumption",
 			Handler:    _MCManagement_GetResourceConsumption_Handler,
 		},
+		{
+			MethodName: "StopServer",
+			Handler:    _MCManagement_StopServer_Handler,
+		},
+		{
+			MethodName: "BroadcastMessage",
+			Handler:    _MCManagement_BroadcastMessage_Handler,
+		},
+		{
+			MethodName: "SendChatMessage",
+			Handler:    _MCManagement_SendChatMessage_Handler,
+		},
+		{
+			MethodName: "RunCommand",
+			Handler:    _MCManagement_RunCommand_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -421,6 +633,11 @@ var MCManagement_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _MCManagement_SubscribeHeartbeat_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "SubscribeChat",
+			Handler:       _MCManagement_SubscribeChat_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "discord-mc-protobuf/proto/mc-management.proto",
 }
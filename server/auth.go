@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/jwt"
+)
+
+const computeScope = "https://www.googleapis.com/auth/compute"
+const legacyPrivateKeyPath = "./certs/google-private-key.txt"
+
+// Finds credentials for GCP compute. They are tried in order:
+//
+//  1. a service-account JSON key file named by GCP_CREDENTIALS_FILE, which
+//     must work if it is set
+//  2. Application Default Credentials (GOOGLE_APPLICATION_CREDENTIALS,
+//     gcloud, or the metadata server)
+//  3. CLIENT_EMAIL plus the raw private key in ./certs/google-private-key.txt
+//
+// GCP_TOKEN_URL overrides the OAuth token endpoint for key-based
+// credentials, e.g. to point the bot at a local stand-in.
+func gcpTokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	tokenURL := os.Getenv("GCP_TOKEN_URL")
+	var problems []string
+
+	if path := os.Getenv("GCP_CREDENTIALS_FILE"); path != "" {
+		// Falling back would quietly run the bot as someone else.
+		ts, err := serviceAccountTokenSource(ctx, path, tokenURL)
+		if err != nil {
+			return nil, fmt.Errorf("GCP_CREDENTIALS_FILE: %w", err)
+		}
+		log.Infof("Using GCP service account key from %v", path)
+		return ts, nil
+	}
+
+	creds, err := google.FindDefaultCredentials(ctx, computeScope)
+	if err == nil {
+		if tokenURL != "" && len(creds.JSON) > 0 {
+			conf, err := google.JWTConfigFromJSON(creds.JSON, computeScope)
+			if err == nil {
+				conf.TokenURL = tokenURL
+				log.Info("Using GCP application default credentials")
+				return conf.TokenSource(ctx), nil
+			}
+			log.WithError(err).Warn("GCP_TOKEN_URL only applies to service account keys, ignoring it")
+		}
+		log.Info("Using GCP application default credentials")
+		return creds.TokenSource, nil
+	}
+	problems = append(problems, fmt.Sprintf("application default credentials: %v", err))
+
+	ts, err := legacyKeyTokenSource(ctx, tokenURL)
+	if err == nil {
+		log.Infof("Using GCP private key from %v", legacyPrivateKeyPath)
+		return ts, nil
+	}
+	problems = append(problems, fmt.Sprintf("CLIENT_EMAIL and %v: %v", legacyPrivateKeyPath, err))
+
+	return nil, fmt.Errorf("no usable GCP credentials (tried %v)", strings.Join(problems, "; "))
+}
+
+func serviceAccountTokenSource(ctx context.Context, path string, tokenURL string) (oauth2.TokenSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	conf, err := google.JWTConfigFromJSON(data, computeScope)
+	if err != nil {
+		return nil, err
+	}
+	if tokenURL != "" {
+		conf.TokenURL = tokenURL
+	}
+	return conf.TokenSource(ctx), nil
+}
+
+func legacyKeyTokenSource(ctx context.Context, tokenURL string) (oauth2.TokenSource, error) {
+	clientEmail := os.Getenv("CLIENT_EMAIL")
+	if clientEmail == "" {
+		return nil, errors.New("CLIENT_EMAIL not set")
+	}
+	privatekey, err := os.ReadFile(legacyPrivateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := &jwt.Config{
+		Email:      clientEmail,
+		PrivateKey: privatekey,
+		Scopes:     []string{computeScope},
+		TokenURL:   google.JWTTokenURL,
+	}
+	if tokenURL != "" {
+		conf.TokenURL = tokenURL
+	}
+	return conf.TokenSource(ctx), nil
+}
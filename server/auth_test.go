@@ -0,0 +1,146 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// Stands in for Google's OAuth token endpoint, handing out a token for
+// any JWT grant, or failing every request if reject is set.
+func startFakeTokenServer(t *testing.T, reject bool) (*httptest.Server, *int32) {
+	var grants int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || r.Form.Get("assertion") == "" {
+			http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+			return
+		}
+		atomic.AddInt32(&grants, 1)
+		if reject {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"token","token_type":"Bearer","expires_in":3600}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &grants
+}
+
+func testPrivateKey(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// Runs the test from an empty directory with no ambient credentials, so
+// only what the test sets up is found.
+func isolateCredentials(t *testing.T) string {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Chdir(dir)
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	setEnv(t, "HOME", dir)
+	setEnv(t, "GOOGLE_APPLICATION_CREDENTIALS", "")
+	setEnv(t, "CLOUDSDK_CONFIG", dir)
+	setEnv(t, "GCP_CREDENTIALS_FILE", "")
+	setEnv(t, "CLIENT_EMAIL", "")
+	return dir
+}
+
+func writeServiceAccountKey(t *testing.T, dir string) string {
+	data, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "bot@project.iam.gserviceaccount.com",
+		"private_key_id": "1",
+		"private_key":    string(testPrivateKey(t)),
+		"token_uri":      "https://oauth2.googleapis.com/token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "key.json")
+	ioutil.WriteFile(path, data, 0600)
+	return path
+}
+
+func TestConnectComputeServiceAccountKey(t *testing.T) {
+	dir := isolateCredentials(t)
+	srv, grants := startFakeTokenServer(t, false)
+	setEnv(t, "GCP_TOKEN_URL", srv.URL)
+	setEnv(t, "GCP_CREDENTIALS_FILE", writeServiceAccountKey(t, dir))
+
+	err := ConnectCompute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *grants != 1 {
+		t.Errorf("token endpoint called %v times, want once", *grants)
+	}
+}
+
+func TestConnectComputeLegacyKey(t *testing.T) {
+	isolateCredentials(t)
+	srv, grants := startFakeTokenServer(t, false)
+	setEnv(t, "GCP_TOKEN_URL", srv.URL)
+	setEnv(t, "CLIENT_EMAIL", "bot@project.iam.gserviceaccount.com")
+	os.Mkdir("certs", 0700)
+	ioutil.WriteFile(legacyPrivateKeyPath, testPrivateKey(t), 0600)
+
+	err := ConnectCompute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *grants != 1 {
+		t.Errorf("token endpoint called %v times, want once", *grants)
+	}
+}
+
+func TestConnectComputeRejectedKey(t *testing.T) {
+	dir := isolateCredentials(t)
+	srv, _ := startFakeTokenServer(t, true)
+	setEnv(t, "GCP_TOKEN_URL", srv.URL)
+	setEnv(t, "GCP_CREDENTIALS_FILE", writeServiceAccountKey(t, dir))
+
+	err := ConnectCompute()
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("got %v, want the token endpoint's error", err)
+	}
+}
+
+func TestConnectComputeBadCredentialsFile(t *testing.T) {
+	isolateCredentials(t)
+	srv, grants := startFakeTokenServer(t, false)
+	setEnv(t, "GCP_TOKEN_URL", srv.URL)
+	setEnv(t, "GCP_CREDENTIALS_FILE", "missing.json")
+	// Would work, but mustn't be used instead of the configured file.
+	setEnv(t, "CLIENT_EMAIL", "bot@project.iam.gserviceaccount.com")
+	os.Mkdir("certs", 0700)
+	ioutil.WriteFile(legacyPrivateKeyPath, testPrivateKey(t), 0600)
+
+	err := ConnectCompute()
+	if err == nil || !strings.Contains(err.Error(), "GCP_CREDENTIALS_FILE") {
+		t.Errorf("got %v, want a GCP_CREDENTIALS_FILE error", err)
+	}
+	if *grants != 0 {
+		t.Error("fell back to other credentials")
+	}
+}
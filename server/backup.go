@@ -0,0 +1,313 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// Kinds of backup. Scheduled backups are pruned per kind; manual backups
+// are never pruned.
+const (
+	BackupManual = "manual"
+	BackupDaily  = "daily"
+	BackupWeekly = "weekly"
+)
+
+const backupCheckInterval = time.Hour
+
+var backupKeepDaily int
+var backupKeepWeekly int
+
+// Set up backup retention, loading from environment where necessary
+func init() {
+	backupKeepDaily = envInt("BACKUP_KEEP_DAILY")
+	backupKeepWeekly = envInt("BACKUP_KEEP_WEEKLY")
+}
+
+func envInt(name string) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.WithError(err).Fatalf("Environment Variable %v must be a number.", name)
+	}
+	return n
+}
+
+// A snapshot of the disk holding the world.
+type Backup struct {
+	Name        string
+	Kind        string
+	TriggeredBy string
+	Created     time.Time
+	SizeGb      int64
+	Status      string
+}
+
+func backupFromSnapshot(s *compute.Snapshot) Backup {
+	created, _ := time.Parse(time.RFC3339, s.CreationTimestamp)
+	return Backup{
+		Name:        s.Name,
+		Kind:        s.Labels["backup-kind"],
+		TriggeredBy: s.Labels["triggered-by"],
+		Created:     created,
+		SizeGb:      s.DiskSizeGb,
+		Status:      s.Status,
+	}
+}
+
+// Returns the disk holding the world: the data disk of a provisioned
+// instance, or the boot disk of one without a data disk.
+func worldDisk(instance *compute.Instance) (*compute.AttachedDisk, error) {
+	var boot *compute.AttachedDisk
+	for _, d := range instance.Disks {
+		if d.DeviceName == dataDiskDeviceName {
+			return d, nil
+		}
+		if d.Boot {
+			boot = d
+		}
+	}
+	if boot == nil {
+		return nil, errors.New("instance has no disks")
+	}
+	return boot, nil
+}
+
+// Snapshots the disk holding the world. triggeredBy is recorded in the
+// snapshot's labels, so it must be a valid label value (e.g. a Discord
+// user ID, or "schedule").
+func CreateBackup(kind string, triggeredBy string) (Backup, error) {
+	instance, err := getInstance()
+	if err != nil {
+		return Backup{}, err
+	}
+	disk, err := worldDisk(instance)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	snapshot := &compute.Snapshot{
+		Name:        fmt.Sprintf("%v-%v-%v", gcpServerName, kind, time.Now().UTC().Format("20060102-150405")),
+		Description: fmt.Sprintf("%v backup of %v", kind, gcpServerName),
+		Labels: map[string]string{
+			"mc-server":    gcpServerName,
+			"backup-kind":  kind,
+			"triggered-by": triggeredBy,
+		},
+	}
+	log.Infof("Creating %v backup %v", kind, snapshot.Name)
+	op, err := gcpComputeService.Disks.CreateSnapshot(gcpProjectId, gcpZone, path.Base(disk.Source), snapshot).Do()
+	if err != nil {
+		return Backup{}, fmt.Errorf("call to snapshot the disk failed: %w", err)
+	}
+	err = waitForOperation(op)
+	if err != nil {
+		return Backup{}, fmt.Errorf("cannot snapshot the disk: %w", err)
+	}
+
+	created, err := gcpComputeService.Snapshots.Get(gcpProjectId, snapshot.Name).Do()
+	if err != nil {
+		return Backup{}, fmt.Errorf("cannot get backup details: %w", err)
+	}
+	return backupFromSnapshot(created), nil
+}
+
+// Lists the backups of the instance, newest first.
+func ListBackups() ([]Backup, error) {
+	var backups []Backup
+	err := gcpComputeService.Snapshots.List(gcpProjectId).
+		Filter(fmt.Sprintf("labels.mc-server = %q", gcpServerName)).
+		Pages(context.Background(), func(list *compute.SnapshotList) error {
+			for _, s := range list.Items {
+				backups = append(backups, backupFromSnapshot(s))
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list backups: %w", err)
+	}
+	markGCPSuccess()
+
+	sort.Slice(backups, func(a, b int) bool {
+		return backups[a].Created.After(backups[b].Created)
+	})
+	return backups, nil
+}
+
+// Replaces the disk holding the world with a new disk created from the
+// given backup. The instance must be stopped. The old disk is detached but
+// kept, and its name is returned so it can be cleaned up (or reattached)
+// by hand. If the new disk can't be attached, the old one is put back.
+func RestoreBackup(name string) (string, error) {
+	snapshot, err := gcpComputeService.Snapshots.Get(gcpProjectId, name).Do()
+	if err != nil {
+		if e, ok := err.(*googleapi.Error); ok && e.Code == 404 {
+			return "", fmt.Errorf("%w: %v", ErrBackupNotFound, name)
+		}
+		return "", fmt.Errorf("cannot get backup details: %w", err)
+	}
+	if snapshot.Labels["mc-server"] != gcpServerName {
+		return "", fmt.Errorf("%w: %v is not a backup of %v", ErrBackupNotFound, name, gcpServerName)
+	}
+
+	instance, err := getInstance()
+	if err != nil {
+		return "", err
+	}
+	if instance.Status != "STOPPED" && instance.Status != "TERMINATED" {
+		return "", fmt.Errorf("%w: status %v", ErrNotStopped, instance.Status)
+	}
+	oldDisk, err := worldDisk(instance)
+	if err != nil {
+		return "", err
+	}
+	oldDiskDetails, err := gcpComputeService.Disks.Get(gcpProjectId, gcpZone, path.Base(oldDisk.Source)).Do()
+	if err != nil {
+		return "", fmt.Errorf("cannot get disk details: %w", err)
+	}
+
+	newDisk := &compute.Disk{
+		Name:           fmt.Sprintf("%v-restored-%v", gcpServerName, time.Now().UTC().Format("20060102-150405")),
+		SourceSnapshot: snapshot.SelfLink,
+		Type:           oldDiskDetails.Type,
+	}
+	log.Infof("Restoring backup %v to disk %v", name, newDisk.Name)
+	op, err := gcpComputeService.Disks.Insert(gcpProjectId, gcpZone, newDisk).Do()
+	if err != nil {
+		return "", fmt.Errorf("call to create the disk failed: %w", err)
+	}
+	err = waitForOperation(op)
+	if err != nil {
+		return "", fmt.Errorf("cannot create the disk: %w", err)
+	}
+
+	op, err = gcpComputeService.Instances.DetachDisk(gcpProjectId, gcpZone, gcpServerName, oldDisk.DeviceName).Do()
+	if err != nil {
+		return "", fmt.Errorf("call to detach the old disk failed: %w", err)
+	}
+	err = waitForOperation(op)
+	if err != nil {
+		return "", fmt.Errorf("cannot detach the old disk: %w", err)
+	}
+
+	err = attachWorldDisk(newDisk.Name, oldDisk)
+	if err != nil {
+		rollbackErr := attachWorldDisk(oldDiskDetails.Name, oldDisk)
+		if rollbackErr != nil {
+			return "", fmt.Errorf("cannot attach restored disk %v (%v), and reattaching old disk %v as %v failed too: %v",
+				newDisk.Name, err, oldDiskDetails.Name, oldDisk.DeviceName, rollbackErr)
+		}
+		return "", fmt.Errorf("cannot attach restored disk %v, old disk %v was reattached: %w", newDisk.Name, oldDiskDetails.Name, err)
+	}
+
+	log.Infof("Backup %v restored, old disk %v kept", name, oldDiskDetails.Name)
+	return oldDiskDetails.Name, nil
+}
+
+// Attaches the named disk to the instance in place of the world disk like,
+// which has been detached.
+func attachWorldDisk(name string, like *compute.AttachedDisk) error {
+	op, err := gcpComputeService.Instances.AttachDisk(gcpProjectId, gcpZone, gcpServerName, &compute.AttachedDisk{
+		Source:     fmt.Sprintf("projects/%v/zones/%v/disks/%v", gcpProjectId, gcpZone, name),
+		DeviceName: like.DeviceName,
+		Boot:       like.Boot,
+		AutoDelete: like.AutoDelete,
+	}).Do()
+	if err != nil {
+		return fmt.Errorf("call to attach the disk failed: %w", err)
+	}
+	err = waitForOperation(op)
+	if err != nil {
+		return fmt.Errorf("cannot attach the disk: %w", err)
+	}
+	return nil
+}
+
+// Deletes all but the newest keep backups of the given kind.
+func pruneBackups(kind string, keep int) error {
+	backups, err := ListBackups()
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	for _, b := range backups {
+		if b.Kind != kind {
+			continue
+		}
+		if kept < keep {
+			kept++
+			continue
+		}
+		log.Infof("Pruning %v backup %v", kind, b.Name)
+		op, err := gcpComputeService.Snapshots.Delete(gcpProjectId, b.Name).Do()
+		if err != nil {
+			return fmt.Errorf("call to delete backup %v failed: %w", b.Name, err)
+		}
+		err = waitForOperation(op)
+		if err != nil {
+			return fmt.Errorf("cannot delete backup %v: %w", b.Name, err)
+		}
+	}
+	return nil
+}
+
+// Takes daily and weekly backups and prunes old ones, keeping the number
+// configured by BACKUP_KEEP_DAILY and BACKUP_KEEP_WEEKLY. A kind with
+// nothing to keep is not scheduled.
+func ScheduleBackups() {
+	if backupKeepDaily <= 0 && backupKeepWeekly <= 0 {
+		log.Info("Scheduled backups are disabled")
+		return
+	}
+
+	for {
+		scheduledBackup(BackupDaily, backupKeepDaily, 24*time.Hour)
+		scheduledBackup(BackupWeekly, backupKeepWeekly, 7*24*time.Hour)
+		time.Sleep(backupCheckInterval)
+	}
+}
+
+func scheduledBackup(kind string, keep int, every time.Duration) {
+	if keep <= 0 {
+		return
+	}
+
+	backups, err := ListBackups()
+	if err != nil {
+		log.WithError(err).Errorf("unable to check %v backups", kind)
+		return
+	}
+	for _, b := range backups {
+		if b.Kind == kind && time.Since(b.Created) < every {
+			return
+		}
+	}
+
+	_, err = CreateBackup(kind, "schedule")
+	if errors.Is(err, ErrNotFound) {
+		return
+	}
+	if err != nil {
+		log.WithError(err).Errorf("unable to take %v backup", kind)
+		return
+	}
+
+	err = pruneBackups(kind, keep)
+	if err != nil {
+		log.WithError(err).Errorf("unable to prune %v backups", kind)
+	}
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	clientCertPath   = "certs/discord-mc-client.crt"
+	clientKeyPath    = "certs/discord-mc-client.key"
+	caCertPath       = "certs/discord-mc.crt"
+	certPollInterval = 30 * time.Second
+)
+
+// Loads the mTLS certificates used to talk to the management server and
+// reloads them whenever the files on disk change.
+type certManager struct {
+	mutex      sync.RWMutex
+	clientCert *tls.Certificate
+	clientLeaf *x509.Certificate
+	ca         *x509.Certificate
+	caPool     *x509.CertPool
+	modTimes   map[string]time.Time
+}
+
+var certs = &certManager{}
+
+// The expiry of one of the certificates used by the bot.
+type CertStatus struct {
+	Name     string
+	NotAfter time.Time
+}
+
+func (c CertStatus) DaysLeft() int {
+	return int(math.Floor(time.Until(c.NotAfter).Hours() / 24))
+}
+
+// Returns the expiry of the client certificate and the CA.
+func CertStatuses() ([]CertStatus, error) {
+	_, err := certs.reloadIfChanged()
+	if err != nil {
+		return nil, err
+	}
+
+	certs.mutex.RLock()
+	defer certs.mutex.RUnlock()
+	return []CertStatus{
+		{Name: "client", NotAfter: certs.clientLeaf.NotAfter},
+		{Name: "ca", NotAfter: certs.ca.NotAfter},
+	}, nil
+}
+
+// Reloads the certificates if any of the files changed since they were
+// last loaded, reporting whether they were reloaded.
+func (c *certManager) reloadIfChanged() (bool, error) {
+	modTimes := make(map[string]time.Time)
+	for _, path := range []string{clientCertPath, clientKeyPath, caCertPath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, fmt.Errorf("cannot stat %v: %w", path, err)
+		}
+		modTimes[path] = info.ModTime()
+	}
+
+	c.mutex.RLock()
+	changed := c.clientCert == nil
+	for path, t := range modTimes {
+		if !c.modTimes[path].Equal(t) {
+			changed = true
+		}
+	}
+	c.mutex.RUnlock()
+	if !changed {
+		return false, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(clientCertPath, clientKeyPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read client cert files: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		return false, fmt.Errorf("failed to parse client cert: %w", err)
+	}
+
+	bs, err := ioutil.ReadFile(caCertPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read ca cert: %w", err)
+	}
+	block, _ := pem.Decode(bs)
+	if block == nil {
+		return false, errors.New("ca cert is not PEM encoded")
+	}
+	ca, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse ca cert: %w", err)
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(bs) {
+		return false, errors.New("failed to append ca cert")
+	}
+
+	c.mutex.Lock()
+	c.clientCert = &certificate
+	c.clientLeaf = leaf
+	c.ca = ca
+	c.caPool = certPool
+	c.modTimes = modTimes
+	c.mutex.Unlock()
+	return true, nil
+}
+
+// Builds transport credentials from the current certificates. The client
+// certificate is looked up on every handshake, so a reloaded certificate
+// is picked up without redialing.
+func (c *certManager) TransportCredentials() (credentials.TransportCredentials, error) {
+	_, err := c.reloadIfChanged()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return credentials.NewTLS(&tls.Config{
+		ServerName: ManagementServerAddress,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			c.mutex.RLock()
+			defer c.mutex.RUnlock()
+			return c.clientCert, nil
+		},
+		RootCAs: c.caPool,
+	}), nil
+}
+
+// Polls the certificate files, reloading them when they change. The
+// management server connection is closed on reload so that a new CA takes
+// effect on the next dial. Never returns.
+func WatchCerts() {
+	certs.watch()
+}
+
+func (c *certManager) watch() {
+	for range time.Tick(certPollInterval) {
+		changed, err := c.reloadIfChanged()
+		if err != nil {
+			log.WithError(err).Debug("unable to check certificates")
+			continue
+		}
+		if changed {
+			log.Info("Certificates changed on disk, reloaded them")
+			management.Close()
+		}
+
+		c.mutex.RLock()
+		metrics.CertExpiryDays.WithLabelValues("client").Set(time.Until(c.clientLeaf.NotAfter).Hours() / 24)
+		metrics.CertExpiryDays.WithLabelValues("ca").Set(time.Until(c.ca.NotAfter).Hours() / 24)
+		c.mutex.RUnlock()
+	}
+}
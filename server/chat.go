@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/mirrorkeydev/discord-mc-bot/proto"
+	log "github.com/sirupsen/logrus"
+)
+
+// A message sent in the MC server's chat.
+type ChatMessage struct {
+	Time    time.Time
+	Player  string
+	Message string
+	// Set for messages that were sent into the game with SendChat, rather
+	// than typed by a player.
+	External bool
+}
+
+// Sends a chat message into the game on behalf of author.
+func SendChat(author, message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := management.Client(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.SendChatMessage(ctx, &pb.SendChatMessageRequest{
+		Author:  author,
+		Message: message,
+	})
+	if err != nil {
+		return fmt.Errorf("could not send chat message: %w", err)
+	}
+	return nil
+}
+
+// Calls handle for every message sent in the MC server's chat. Never
+// returns.
+func WatchChat(handle func(ChatMessage)) {
+	keepSubscribed("chat", func(client pb.MCManagementClient) error {
+		stream, err := client.SubscribeChat(context.Background(), &pb.SubscribeChatRequest{})
+		if err != nil {
+			return fmt.Errorf("could not subscribe to chat: %w", err)
+		}
+		log.Info("Subscribed to MC server chat!")
+
+		for {
+			r, err := stream.Recv()
+			if err != nil {
+				return err
+			}
+			handle(ChatMessage{
+				Time:     r.Timestamp.AsTime(),
+				Player:   r.Message.GetPlayerName(),
+				Message:  r.Message.GetMessage(),
+				External: r.Message.GetExternal(),
+			})
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	pb "github.com/mirrorkeydev/discord-mc-bot/proto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+)
+
+const (
+	// How long to wait before subscribing to a stream again after it
+	// broke, e.g. because the instance went down.
+	resubscribeInterval   = 30 * time.Second
+	managementDialTimeout = 10 * time.Second
+	managementBaseBackoff = time.Second
+	managementMaxBackoff  = time.Minute
+)
+
+// Owns the connection to the management server. The management server
+// (which is hosted on the same instance as the MC server) is only up while
+// the instance is, so the connection is dialed lazily, with a bounded
+// timeout, and failed dials back off exponentially instead of being
+// retried by every caller.
+//
+// The mutex only guards the fields; dialing and waiting for the connection
+// to become ready happen without it, so State never blocks on the network.
+type connectionManager struct {
+	mutex       sync.Mutex
+	conn        *grpc.ClientConn
+	client      pb.MCManagementClient
+	backoff     time.Duration
+	nextAttempt time.Time
+	// Closed when the dial in progress, if any, finishes. Callers that
+	// need a connection while one is being dialed wait for it rather than
+	// dialing again.
+	dialing chan struct{}
+	// Bumped by Close, so that a dial that was in flight when the
+	// connection was closed doesn't bring it back.
+	generation int
+}
+
+var management = &connectionManager{}
+
+// Returns a client for the management server, dialing it first if there is
+// no usable connection. Fails with ErrManagementUnavailable if the server
+// can't be reached before ctx (or the dial timeout) expires.
+func (m *connectionManager) Client(ctx context.Context) (pb.MCManagementClient, error) {
+	conn, client, err := m.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, managementDialTimeout)
+	defer cancel()
+	for state := conn.GetState(); state != connectivity.Ready; state = conn.GetState() {
+		if !conn.WaitForStateChange(waitCtx, state) {
+			return nil, fmt.Errorf("%w: connection is %v", ErrManagementUnavailable, state)
+		}
+	}
+	return client, nil
+}
+
+// Returns the current connection, dialing one if there is none. Only one
+// dial runs at a time.
+func (m *connectionManager) connection(ctx context.Context) (*grpc.ClientConn, pb.MCManagementClient, error) {
+	for {
+		m.mutex.Lock()
+		if m.conn != nil && m.conn.GetState() != connectivity.Shutdown {
+			conn, client := m.conn, m.client
+			m.mutex.Unlock()
+			return conn, client, nil
+		}
+		if dialing := m.dialing; dialing != nil {
+			m.mutex.Unlock()
+			select {
+			case <-dialing:
+				continue
+			case <-ctx.Done():
+				return nil, nil, fmt.Errorf("%w: %v", ErrManagementUnavailable, ctx.Err())
+			}
+		}
+		if wait := time.Until(m.nextAttempt); wait > 0 {
+			m.mutex.Unlock()
+			return nil, nil, fmt.Errorf("%w: backing off for %v", ErrManagementUnavailable, wait.Round(time.Second))
+		}
+		dialing := make(chan struct{})
+		m.dialing = dialing
+		generation := m.generation
+		m.mutex.Unlock()
+
+		conn, err := m.dial(ctx)
+
+		m.mutex.Lock()
+		m.dialing = nil
+		close(dialing)
+		if err == nil && generation != m.generation {
+			conn.Close()
+			err = fmt.Errorf("%w: connection was closed while dialing", ErrManagementUnavailable)
+		} else if err == nil {
+			m.backoff = 0
+			m.nextAttempt = time.Time{}
+			m.conn = conn
+			m.client = pb.NewMCManagementClient(conn)
+			go watchConnection(conn)
+			log.Info("Connected to MC management server!")
+		} else if generation == m.generation {
+			if m.backoff == 0 {
+				m.backoff = managementBaseBackoff
+			} else if m.backoff *= 2; m.backoff > managementMaxBackoff {
+				m.backoff = managementMaxBackoff
+			}
+			m.nextAttempt = time.Now().Add(m.backoff)
+			log.WithError(err).Warnf("unable to connect to management server, retrying in %v", m.backoff)
+		}
+		conn, client := m.conn, m.client
+		m.mutex.Unlock()
+		if err != nil {
+			return nil, nil, err
+		}
+		return conn, client, nil
+	}
+}
+
+// Dials the management server. Must be called without the mutex held.
+func (m *connectionManager) dial(ctx context.Context) (*grpc.ClientConn, error) {
+	creds, err := certs.TransportCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrManagementUnavailable, err)
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, managementDialTimeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(
+		dialCtx,
+		fmt.Sprintf("%v:%v", ManagementServerAddress, managementServerPort),
+		grpc.WithBlock(),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff: backoff.Config{
+				BaseDelay:  managementBaseBackoff,
+				Multiplier: 2,
+				Jitter:     0.2,
+				MaxDelay:   managementMaxBackoff,
+			},
+			MinConnectTimeout: managementDialTimeout,
+		}),
+		grpc.WithUnaryInterceptor(metrics.UnaryClientInterceptor),
+		grpc.WithStreamInterceptor(metrics.StreamClientInterceptor),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrManagementUnavailable, err)
+	}
+	return conn, nil
+}
+
+// Logs connectivity state transitions until the connection is closed.
+func watchConnection(conn *grpc.ClientConn) {
+	state := conn.GetState()
+	for state != connectivity.Shutdown {
+		conn.WaitForStateChange(context.Background(), state)
+		newState := conn.GetState()
+		log.Infof("Management server connection moved from %v to %v", state, newState)
+		state = newState
+	}
+}
+
+// Returns the state of the connection. A connection that was never dialed
+// is reported as Shutdown.
+func (m *connectionManager) State() connectivity.State {
+	m.mutex.Lock()
+	conn := m.conn
+	m.mutex.Unlock()
+
+	if conn == nil {
+		return connectivity.Shutdown
+	}
+	return conn.GetState()
+}
+
+// Closes the connection, e.g. because the instance is going down. The next
+// call to Client dials again without waiting out any backoff.
+func (m *connectionManager) Close() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.conn != nil {
+		m.conn.Close()
+		m.conn = nil
+		m.client = nil
+	}
+	m.generation++
+	m.backoff = 0
+	m.nextAttempt = time.Time{}
+}
+
+// Runs subscribe, which should follow a management server stream until it
+// breaks, whenever the instance is up. The subscription is renewed after
+// it breaks, so the stream keeps flowing across instance restarts. Never
+// returns.
+func keepSubscribed(name string, subscribe func(client pb.MCManagementClient) error) {
+	for {
+		up, err := IsUp()
+		if err == nil && up {
+			ctx, cancel := context.WithTimeout(context.Background(), managementDialTimeout)
+			client, err := management.Client(ctx)
+			cancel()
+			if err == nil {
+				err = subscribe(client)
+			}
+			log.WithError(err).Debugf("%v subscription ended", name)
+		}
+		time.Sleep(resubscribeInterval)
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mirrorkeydev/discord-mc-bot/dns"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+const dnsUpdateTimeout = 3 * time.Minute
+
+var dnsProvider dns.Provider
+var dnsResolver dns.Resolver
+
+// Set up the DNS provider, loading from environment where necessary
+func init() {
+	switch provider := os.Getenv("DNS_PROVIDER"); provider {
+	case "":
+		log.Info("DNS_PROVIDER not set, the server address will not be updated")
+	case "cloudflare":
+		token := os.Getenv("CLOUDFLARE_API_TOKEN")
+		zoneID := os.Getenv("CLOUDFLARE_ZONE_ID")
+		if token == "" || zoneID == "" {
+			log.Fatal("Environment Variables CLOUDFLARE_API_TOKEN and CLOUDFLARE_ZONE_ID must be set to use Cloudflare DNS.")
+		}
+		dnsProvider = dns.NewCloudflare(os.Getenv("DNS_API_URL"), token, zoneID)
+	default:
+		log.Fatalf("Unknown DNS_PROVIDER %v.", provider)
+	}
+
+	if resolver := os.Getenv("DNS_RESOLVER"); resolver != "" {
+		dnsResolver = dns.ResolverFor(resolver)
+	}
+}
+
+// Returns the NAT IP of the instance's first network interface, or "" if
+// it has none.
+func externalIP(instance *compute.Instance) string {
+	for _, ni := range instance.NetworkInterfaces {
+		for _, ac := range ni.AccessConfigs {
+			if ac.NatIP != "" {
+				return ac.NatIP
+			}
+		}
+	}
+	return ""
+}
+
+// Returns the instance's current external IP.
+func ExternalIP() (string, error) {
+	instance, err := getInstance()
+	if err != nil {
+		return "", err
+	}
+	ip := externalIP(instance)
+	if ip == "" {
+		return "", fmt.Errorf("instance has no external IP")
+	}
+	return ip, nil
+}
+
+// Points ManagementServerAddress at the instance's external IP and waits
+// for the change to propagate. Does nothing if no DNS provider is set up.
+func updateDNS() error {
+	if dnsProvider == nil {
+		return nil
+	}
+
+	ip, err := ExternalIP()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDNSUpdate, err)
+	}
+	return pointDNSAt(ip)
+}
+
+func pointDNSAt(ip string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsUpdateTimeout)
+	defer cancel()
+
+	log.Infof("Pointing %v at %v", ManagementServerAddress, ip)
+	err := dnsProvider.UpdateRecord(ctx, ManagementServerAddress, ip)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDNSUpdate, err)
+	}
+
+	err = dns.WaitForPropagation(ctx, dnsResolver, ManagementServerAddress, ip)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDNSUpdate, err)
+	}
+	log.Infof("%v now resolves to %v", ManagementServerAddress, ip)
+	return nil
+}
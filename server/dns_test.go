@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mirrorkeydev/discord-mc-bot/dns"
+)
+
+type failingProvider struct{}
+
+func (failingProvider) UpdateRecord(ctx context.Context, name string, ip string) error {
+	return errors.New("API is down")
+}
+
+func TestPointDNSAt(t *testing.T) {
+	defer func(p dns.Provider, r dns.Resolver) {
+		dnsProvider, dnsResolver = p, r
+	}(dnsProvider, dnsResolver)
+
+	fake := dns.NewFake()
+	dnsProvider, dnsResolver = fake, fake
+
+	err := pointDNSAt("192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip, _ := fake.Lookup(ManagementServerAddress); ip != "192.0.2.1" {
+		t.Errorf("%v points at %q", ManagementServerAddress, ip)
+	}
+
+	err = pointDNSAt("192.0.2.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip, _ := fake.Lookup(ManagementServerAddress); ip != "192.0.2.2" {
+		t.Errorf("%v points at %q after the IP changed", ManagementServerAddress, ip)
+	}
+
+	dnsProvider = failingProvider{}
+	err = pointDNSAt("192.0.2.3")
+	if !errors.Is(err, ErrDNSUpdate) {
+		t.Errorf("got %v, want ErrDNSUpdate", err)
+	}
+}
+
+func TestUpdateDNSWithoutProvider(t *testing.T) {
+	defer func(p dns.Provider) { dnsProvider = p }(dnsProvider)
+	dnsProvider = nil
+
+	// Returns before asking GCP for the IP.
+	if err := updateDNS(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/mirrorkeydev/discord-mc-bot/proto"
+)
+
+var (
+	// ErrNotFound is returned when the GCP instance does not exist.
+	ErrNotFound = errors.New("instance not found")
+	// ErrSuspended is returned when the GCP instance is suspended and
+	// can't be driven to the requested state.
+	ErrSuspended = errors.New("instance is suspended")
+	// ErrNotRunning is returned when an action needs the instance to be
+	// running first.
+	ErrNotRunning = errors.New("instance is not running")
+	// ErrManagementUnavailable is returned when the management server
+	// running on the instance can't be reached.
+	ErrManagementUnavailable = errors.New("management server unavailable")
+	// ErrNotStopped is returned when an action needs the instance to be
+	// stopped first.
+	ErrNotStopped = errors.New("instance is not stopped")
+	// ErrInvalidMachineType is returned when a machine type isn't
+	// available in the instance's zone.
+	ErrInvalidMachineType = errors.New("invalid machine type")
+	// ErrDNSUpdate is returned alongside a successful start when the
+	// server's DNS record could not be pointed at the new address.
+	ErrDNSUpdate = errors.New("dns update failed")
+	// ErrBackupNotFound is returned when a backup snapshot does not exist.
+	ErrBackupNotFound = errors.New("backup not found")
+	// ErrProvisioning is returned when a new instance can't be created
+	// because settings or files it needs are missing.
+	ErrProvisioning = errors.New("cannot provision instance")
+)
+
+// UpResult describes what BringUpServer had to do to get the instance running.
+type UpResult int
+
+const (
+	UpAlreadyRunning UpResult = iota
+	UpStarted
+	UpCreated
+	UpResumed
+)
+
+// DownResult describes what BringDownServer had to do to get the instance stopped.
+type DownResult int
+
+const (
+	DownAlreadyStopped DownResult = iota
+	DownStopped
+	// The instance was stopped, but the MC server didn't confirm that it
+	// had saved the world first.
+	DownStoppedUnclean
+)
+
+// SuspendResult describes what SuspendServer had to do to get the instance suspended.
+type SuspendResult int
+
+const (
+	SuspendAlreadySuspended SuspendResult = iota
+	SuspendSuspended
+)
+
+// Phase is a step of a multi-step lifecycle action, reported to the
+// caller as it begins.
+type Phase int
+
+const (
+	PhaseStopping Phase = iota
+	PhaseStarting
+	PhaseResizing
+	PhaseResetting
+	PhaseSaving
+	PhaseWaitingForMinecraft
+)
+
+// WhitelistError is returned when the management server processes a
+// whitelist request but does not report success.
+type WhitelistError struct {
+	Code     pb.UpdateWhitelistResponse_WhitelistResult
+	Response string
+}
+
+func (e *WhitelistError) Error() string {
+	return fmt.Sprintf("whitelist operation failed (%v): %v", e.Code, e.Response)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/mirrorkeydev/discord-mc-bot/proto"
+	log "github.com/sirupsen/logrus"
+)
+
+// A player dying on the MC server.
+type DeathEvent struct {
+	Time    time.Time
+	Player  string
+	Message string
+}
+
+// Calls onDeath every time a player dies. Never returns.
+func WatchPlayerEvents(onDeath func(DeathEvent)) {
+	keepSubscribed("player event", func(client pb.MCManagementClient) error {
+		stream, err := client.SubscribePlayerEvent(context.Background(), &pb.SubscribePlayerEventRequest{})
+		if err != nil {
+			return fmt.Errorf("could not subscribe to player events: %w", err)
+		}
+		log.Info("Subscribed to MC server player events!")
+
+		for {
+			r, err := stream.Recv()
+			if err != nil {
+				return err
+			}
+			t := time.Now()
+			if r.Timestamp != nil {
+				t = r.Timestamp.AsTime()
+			}
+			if d := r.GetDeathEvent(); d != nil {
+				onDeath(DeathEvent{Time: t, Player: d.PlayerName, Message: d.Msg})
+			}
+		}
+	})
+}
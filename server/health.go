@@ -0,0 +1,38 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+var lastGCPSuccess struct {
+	sync.Mutex
+	at time.Time
+}
+
+func markGCPSuccess() {
+	lastGCPSuccess.Lock()
+	lastGCPSuccess.at = time.Now()
+	lastGCPSuccess.Unlock()
+}
+
+// Returns when a call to GCP compute last succeeded, or the zero time if
+// none has yet.
+func LastGCPSuccess() time.Time {
+	lastGCPSuccess.Lock()
+	defer lastGCPSuccess.Unlock()
+	return lastGCPSuccess.at
+}
+
+// Reports whether the GCP compute service was set up.
+func ComputeReady() bool {
+	return gcpComputeService != nil
+}
+
+// Returns the state of the connection to the management server. A
+// connection that was never dialed is reported as Shutdown.
+func ManagementServerState() connectivity.State {
+	return management.State()
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	pingTimeout = 5 * time.Second
+	// Any version works for a status request; the server answers with its
+	// own.
+	pingProtocolVersion = 47
+	// Status responses are small, anything bigger is not a MC server.
+	pingMaxPacketSize = 1 << 20
+)
+
+var formattingCode = regexp.MustCompile("§.")
+
+// Removes Minecraft formatting codes, like §c for red, from text.
+func StripFormatting(text string) string {
+	return formattingCode.ReplaceAllString(text, "")
+}
+
+// What the MC server reports about itself in the multiplayer server list.
+type MinecraftStatus struct {
+	MOTD    string
+	Version string
+	Online  int
+	Max     int
+	Players []string
+	Latency time.Duration
+}
+
+// Asks the MC server for its status using the Server List Ping protocol,
+// the same way the multiplayer menu does. See https://wiki.vg/Server_List_Ping
+func PingMinecraft(ctx context.Context) (*MinecraftStatus, error) {
+	status, err := pingMinecraft(ctx)
+	if err != nil {
+		metrics.MinecraftJoinable.Set(0)
+		return nil, err
+	}
+	metrics.MinecraftJoinable.Set(1)
+	metrics.MinecraftLatency.Set(status.Latency.Seconds())
+	return status, nil
+}
+
+func pingMinecraft(ctx context.Context) (*MinecraftStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ManagementServerAddress, minecraftPort))
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach the MC server: %w", err)
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	r := bufio.NewReader(conn)
+
+	port, _ := strconv.Atoi(minecraftPort)
+	var handshake bytes.Buffer
+	writeVarInt(&handshake, 0x00)
+	writeVarInt(&handshake, pingProtocolVersion)
+	writeVarInt(&handshake, int32(len(ManagementServerAddress)))
+	handshake.WriteString(ManagementServerAddress)
+	binary.Write(&handshake, binary.BigEndian, uint16(port))
+	writeVarInt(&handshake, 1) // next state: status
+	err = writePacket(conn, handshake.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	err = writePacket(conn, []byte{0x00})
+	if err != nil {
+		return nil, err
+	}
+
+	packet, err := readPacket(r, 0x00)
+	if err != nil {
+		return nil, err
+	}
+	length, err := binary.ReadUvarint(packet)
+	if err != nil || length > uint64(packet.Len()) {
+		return nil, errors.New("malformed status response")
+	}
+	var response struct {
+		Version struct {
+			Name string `json:"name"`
+		} `json:"version"`
+		Players struct {
+			Max    int `json:"max"`
+			Online int `json:"online"`
+			Sample []struct {
+				Name string `json:"name"`
+			} `json:"sample"`
+		} `json:"players"`
+		Description json.RawMessage `json:"description"`
+	}
+	err = json.Unmarshal(packet.Next(int(length)), &response)
+	if err != nil {
+		return nil, fmt.Errorf("malformed status response: %w", err)
+	}
+
+	status := &MinecraftStatus{
+		MOTD:    chatText(response.Description),
+		Version: response.Version.Name,
+		Online:  response.Players.Online,
+		Max:     response.Players.Max,
+	}
+	for _, p := range response.Players.Sample {
+		status.Players = append(status.Players, p.Name)
+	}
+
+	// The latency is measured with a separate ping, as the status may take
+	// the server a while to put together.
+	var ping bytes.Buffer
+	writeVarInt(&ping, 0x01)
+	payload := rand.Int63()
+	binary.Write(&ping, binary.BigEndian, payload)
+	sent := time.Now()
+	err = writePacket(conn, ping.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	_, err = readPacket(r, 0x01)
+	if err != nil {
+		return nil, err
+	}
+	status.Latency = time.Since(sent)
+	return status, nil
+}
+
+// Flattens a chat component, which is either a plain string or an object
+// with text and extra components, into plain text.
+func chatText(raw json.RawMessage) string {
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return strings.TrimSpace(StripFormatting(text))
+	}
+	var component struct {
+		Text  string            `json:"text"`
+		Extra []json.RawMessage `json:"extra"`
+	}
+	if json.Unmarshal(raw, &component) != nil {
+		return ""
+	}
+	text = component.Text
+	for _, e := range component.Extra {
+		text += chatText(e)
+	}
+	return strings.TrimSpace(StripFormatting(text))
+}
+
+func writeVarInt(buf *bytes.Buffer, v int32) {
+	b := make([]byte, binary.MaxVarintLen32)
+	n := binary.PutUvarint(b, uint64(uint32(v)))
+	buf.Write(b[:n])
+}
+
+func writePacket(w io.Writer, data []byte) error {
+	var buf bytes.Buffer
+	writeVarInt(&buf, int32(len(data)))
+	buf.Write(data)
+	_, err := w.Write(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("cannot talk to the MC server: %w", err)
+	}
+	return nil
+}
+
+// Reads a packet, checking that it has the expected ID, and returns its
+// payload.
+func readPacket(r *bufio.Reader, id uint64) (*bytes.Buffer, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read from the MC server: %w", err)
+	}
+	if length == 0 || length > pingMaxPacketSize {
+		return nil, fmt.Errorf("bad packet length %v from the MC server", length)
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read from the MC server: %w", err)
+	}
+	packet := bytes.NewBuffer(data)
+	got, err := binary.ReadUvarint(packet)
+	if err != nil || got != id {
+		return nil, fmt.Errorf("unexpected packet %v from the MC server", got)
+	}
+	return packet, nil
+}
+
+// How far up the stack the server is, from the VM to Minecraft itself.
+type Status struct {
+	// The GCP instance status, or NOT_FOUND if there is no instance.
+	VM string
+	// Whether the management server is reachable.
+	Management bool
+	// What the MC server reports in the server list; nil if it isn't
+	// accepting connections.
+	Minecraft *MinecraftStatus
+}
+
+func (s Status) VMUp() bool {
+	return s.VM == "RUNNING"
+}
+
+func (s Status) Joinable() bool {
+	return s.Minecraft != nil
+}
+
+// Checks each layer of the server in turn. Layers above one that is down
+// are not checked.
+func CurrentStatus() (Status, error) {
+	var status Status
+	instance, err := getInstance()
+	if errors.Is(err, ErrNotFound) {
+		status.VM = "NOT_FOUND"
+	} else if err != nil {
+		return status, err
+	} else {
+		status.VM = instance.Status
+	}
+	if !status.VMUp() {
+		metrics.MinecraftJoinable.Set(0)
+		return status, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	_, err = management.Client(ctx)
+	status.Management = err == nil
+
+	status.Minecraft, err = PingMinecraft(context.Background())
+	if err != nil {
+		log.WithError(err).Debug("MC server isn't answering pings")
+	}
+	return status, nil
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// What the fake MC server saw in the handshake.
+type pingHandshake struct {
+	protocol  uint64
+	address   string
+	port      uint16
+	nextState uint64
+}
+
+// A stand-in for the MC server's game port that answers one server list
+// ping with status, or with reply instead of a status response if it is
+// set.
+func startFakeSLP(t *testing.T, status string, reply []byte) <-chan pingHandshake {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldAddress, oldPort := ManagementServerAddress, minecraftPort
+	ManagementServerAddress, minecraftPort, _ = net.SplitHostPort(l.Addr().String())
+	t.Cleanup(func() {
+		ManagementServerAddress, minecraftPort = oldAddress, oldPort
+		l.Close()
+	})
+
+	handshakes := make(chan pingHandshake, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+
+		packet, err := readPacket(r, 0x00)
+		if err != nil {
+			return
+		}
+		var h pingHandshake
+		h.protocol, _ = binary.ReadUvarint(packet)
+		length, _ := binary.ReadUvarint(packet)
+		h.address = string(packet.Next(int(length)))
+		binary.Read(packet, binary.BigEndian, &h.port)
+		h.nextState, _ = binary.ReadUvarint(packet)
+		handshakes <- h
+
+		if _, err := readPacket(r, 0x00); err != nil {
+			return
+		}
+		if reply != nil {
+			conn.Write(reply)
+			return
+		}
+		var response bytes.Buffer
+		writeVarInt(&response, 0x00)
+		writeVarInt(&response, int32(len(status)))
+		response.WriteString(status)
+		writePacket(conn, response.Bytes())
+
+		// Echo the latency ping back.
+		ping, err := readPacket(r, 0x01)
+		if err != nil {
+			return
+		}
+		var pong bytes.Buffer
+		writeVarInt(&pong, 0x01)
+		pong.Write(ping.Bytes())
+		writePacket(conn, pong.Bytes())
+	}()
+	return handshakes
+}
+
+func TestPingMinecraft(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := []struct {
+		name   string
+		status string
+		want   MinecraftStatus
+	}{
+		{
+			name:   "plain description",
+			status: `{"version":{"name":"1.18.1","protocol":757},"players":{"max":20,"online":0},"description":"§aA §lMinecraft§r Server "}`,
+			want:   MinecraftStatus{MOTD: "A Minecraft Server", Version: "1.18.1", Max: 20},
+		},
+		{
+			name: "chat component description",
+			status: `{"version":{"name":"Paper 1.18.1"},"players":{"max":10,"online":2,"sample":[{"name":"Alex","id":"1"},{"name":"Steve","id":"2"}]},` +
+				`"description":{"text":"Hello ","extra":[{"text":"§cworld"},"!"]}}`,
+			want: MinecraftStatus{MOTD: "Hello world!", Version: "Paper 1.18.1", Online: 2, Max: 10, Players: []string{"Alex", "Steve"}},
+		},
+		{
+			// Long enough that the lengths need more than one VarInt byte.
+			name:   "long description",
+			status: `{"version":{"name":"1.18.1"},"players":{"max":20,"online":0},"description":"` + long + `"}`,
+			want:   MinecraftStatus{MOTD: long, Version: "1.18.1", Max: 20},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handshakes := startFakeSLP(t, test.status, nil)
+
+			got, err := pingMinecraft(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Latency <= 0 {
+				t.Error("no latency measured")
+			}
+			got.Latency = 0
+			if !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("got %+v, want %+v", *got, test.want)
+			}
+
+			h := <-handshakes
+			port, _ := strconv.Atoi(minecraftPort)
+			want := pingHandshake{protocol: pingProtocolVersion, address: ManagementServerAddress, port: uint16(port), nextState: 1}
+			if h != want {
+				t.Errorf("handshake %+v, want %+v", h, want)
+			}
+		})
+	}
+}
+
+func TestPingMinecraftBadResponse(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply []byte
+	}{
+		{name: "not a MC server", reply: []byte("HTTP/1.1 400 Bad Request\r\n\r\n")},
+		{name: "wrong packet", reply: []byte{2, 0x05, 0x00}},
+		{name: "bad json", reply: []byte{4, 0x00, 2, '{', '{'}},
+		{name: "truncated", reply: []byte{10, 0x00, 8, '{'}},
+		{name: "hang up", reply: []byte{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			startFakeSLP(t, "", test.reply)
+			_, err := pingMinecraft(context.Background())
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestVarInt(t *testing.T) {
+	tests := []struct {
+		value int32
+		want  []byte
+	}{
+		{0, []byte{0x00}},
+		{1, []byte{0x01}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{25565, []byte{0xdd, 0xc7, 0x01}},
+		{2147483647, []byte{0xff, 0xff, 0xff, 0xff, 0x07}},
+		{-1, []byte{0xff, 0xff, 0xff, 0xff, 0x0f}},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		writeVarInt(&buf, test.value)
+		if !bytes.Equal(buf.Bytes(), test.want) {
+			t.Errorf("writeVarInt(%v) = % x, want % x", test.value, buf.Bytes(), test.want)
+		}
+	}
+}
+
+func TestReadPacket(t *testing.T) {
+	var buf bytes.Buffer
+	writePacket(&buf, []byte{0x01, 'h', 'i'})
+	packet, err := readPacket(bufio.NewReader(&buf), 0x01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := packet.String(); got != "hi" {
+		t.Errorf("payload %q, want %q", got, "hi")
+	}
+
+	_, err = readPacket(bufio.NewReader(bytes.NewReader([]byte{0x05, 0x01})), 0x01)
+	if err == nil || !strings.Contains(err.Error(), "cannot read") {
+		t.Errorf("got %v for a truncated packet", err)
+	}
+	_, err = readPacket(bufio.NewReader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0x7f})), 0x01)
+	if err == nil {
+		t.Error("expected an error for an oversized packet")
+	}
+}
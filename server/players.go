@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
+	pb "github.com/mirrorkeydev/discord-mc-bot/proto"
+	log "github.com/sirupsen/logrus"
+)
+
+var minecraftName = regexp.MustCompile(`^\w{3,16}$`)
+
+// Reports whether name could be a Minecraft username.
+func ValidMinecraftName(name string) bool {
+	return minecraftName.MatchString(name)
+}
+
+// The players online on the MC server, as pushed by the management server.
+type PlayerUpdate struct {
+	Time    time.Time
+	Players []string
+	// Set on the first update after (re)subscribing, which tells the
+	// current state rather than a change.
+	First bool
+	// Set when the subscription broke, e.g. because the instance went
+	// down. Players is empty, as nobody can be seen online any more.
+	Lost bool
+}
+
+// Calls handle every time the set of players online changes, and once
+// more when the subscription breaks. Never returns.
+func WatchPlayers(handle func(PlayerUpdate)) {
+	keepSubscribed("player count", func(client pb.MCManagementClient) error {
+		stream, err := client.SubscribePlayerCount(context.Background(), &pb.SubscribePlayerCountRequest{})
+		if err != nil {
+			return fmt.Errorf("could not subscribe to player count: %w", err)
+		}
+		log.Info("Subscribed to MC server player count!")
+
+		first := true
+		for {
+			r, err := stream.Recv()
+			if err != nil {
+				handle(PlayerUpdate{Time: time.Now(), Lost: true})
+				return err
+			}
+			metrics.PlayerCount.Set(float64(r.Response.GetPlayerCount()))
+			t := time.Now()
+			if ts := r.Response.GetTimestamp(); ts != nil {
+				t = ts.AsTime()
+			}
+			handle(PlayerUpdate{
+				Time:    t,
+				Players: r.Response.GetPlayerNames(),
+				First:   first,
+			})
+			first = false
+		}
+	})
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+const (
+	preemptionPollInterval = 30 * time.Second
+	// How long the bot expects the instance to be going down after it
+	// asked for that, so the stop isn't mistaken for a preemption.
+	expectedStopWindow = 10 * time.Minute
+	// Restart attempts are counted again from zero once the instance has
+	// stayed up this long.
+	preemptionStableUptime = time.Hour
+	// Wait between failed restarts, doubling each time up to the max.
+	// Spot capacity often runs out right after a preemption.
+	preemptionRetryBackoff    = time.Minute
+	preemptionMaxRetryBackoff = 16 * time.Minute
+)
+
+var gcpSpot bool
+var preemptionMaxRestarts int
+
+// Set up spot scheduling, loading from environment where necessary
+func init() {
+	gcpSpot = os.Getenv("GCP_SPOT") == "true"
+	preemptionMaxRestarts = envInt("PREEMPTION_MAX_RESTARTS")
+}
+
+// Scheduling for new instances: spot VMs are much cheaper, but GCP may
+// stop them at any time.
+func instanceScheduling() *compute.Scheduling {
+	if !gcpSpot {
+		return nil
+	}
+	automaticRestart := false
+	return &compute.Scheduling{
+		ProvisioningModel:         "SPOT",
+		InstanceTerminationAction: "STOP",
+		OnHostMaintenance:         "TERMINATE",
+		AutomaticRestart:          &automaticRestart,
+	}
+}
+
+var expectedStop struct {
+	sync.Mutex
+	until time.Time
+}
+
+// Marks that the bot is about to stop (or suspend) the instance.
+func expectStop() {
+	expectedStop.Lock()
+	expectedStop.until = time.Now().Add(expectedStopWindow)
+	expectedStop.Unlock()
+}
+
+func stopWasExpected() bool {
+	expectedStop.Lock()
+	defer expectedStop.Unlock()
+	return time.Now().Before(expectedStop.until)
+}
+
+type PreemptionEventType int
+
+const (
+	// The instance was preempted. Attempt is the restart about to be
+	// tried, or 0 if it won't be restarted.
+	Preempted PreemptionEventType = iota
+	PreemptionRestarted
+	PreemptionRestartFailed
+)
+
+type PreemptionEvent struct {
+	Type        PreemptionEventType
+	Attempt     int
+	MaxAttempts int
+	// Whether GCP reported a preemption, rather than the instance just
+	// stopping without the bot asking it to.
+	Confirmed bool
+	Err       error
+	// After a failed restart, how long until the next attempt, or 0 if
+	// there won't be one.
+	RetryIn time.Duration
+}
+
+// Watches the instance for preemptions, restarting it up to
+// PREEMPTION_MAX_RESTARTS times in a row, backing off between failed
+// restarts. notify is called for every preemption and restart attempt.
+// Does nothing unless GCP_SPOT is set.
+func WatchPreemptions(notify func(PreemptionEvent)) {
+	if !gcpSpot {
+		return
+	}
+
+	watchStart := time.Now()
+	seenOps := make(map[string]bool)
+	lastStatus := ""
+	var runningSince time.Time
+	handled := false
+	attempts := 0
+	// Set while the instance is waiting to be restarted.
+	var nextRestart time.Time
+
+	for range time.Tick(preemptionPollInterval) {
+		instance, err := getInstance()
+		if err != nil {
+			continue
+		}
+
+		if instance.Status == "RUNNING" {
+			if lastStatus != "RUNNING" {
+				runningSince = time.Now()
+				handled = false
+				// Someone else brought it back up.
+				nextRestart = time.Time{}
+			}
+			if time.Since(runningSince) > preemptionStableUptime {
+				attempts = 0
+			}
+		}
+
+		newOp, err := newPreemptionOperation(watchStart, seenOps)
+		if err != nil {
+			log.WithError(err).Warn("unable to check for preemption operations")
+		}
+		stoppedUnexpectedly := lastStatus == "RUNNING" && isStoppedStatus(instance.Status) && !stopWasExpected()
+		lastStatus = instance.Status
+
+		if !handled && (newOp || stoppedUnexpectedly) {
+			handled = true
+			log.Warnf("Instance was preempted (status %v)", instance.Status)
+
+			event := PreemptionEvent{Type: Preempted, MaxAttempts: preemptionMaxRestarts, Confirmed: newOp}
+			if attempts < preemptionMaxRestarts {
+				event.Attempt = attempts + 1
+				nextRestart = time.Now()
+			}
+			notify(event)
+		}
+
+		if nextRestart.IsZero() || time.Now().Before(nextRestart) {
+			continue
+		}
+		attempts++
+		_, err = BringUpServer()
+		if err == nil {
+			nextRestart = time.Time{}
+			notify(PreemptionEvent{Type: PreemptionRestarted, Attempt: attempts, MaxAttempts: preemptionMaxRestarts})
+			continue
+		}
+
+		event := PreemptionEvent{Type: PreemptionRestartFailed, Attempt: attempts, MaxAttempts: preemptionMaxRestarts, Err: err}
+		if attempts < preemptionMaxRestarts {
+			event.RetryIn = restartBackoff(attempts)
+			nextRestart = time.Now().Add(event.RetryIn)
+		} else {
+			nextRestart = time.Time{}
+		}
+		notify(event)
+	}
+}
+
+// How long to wait before retrying after the given number of failed
+// restarts.
+func restartBackoff(failed int) time.Duration {
+	d := preemptionRetryBackoff
+	for n := 1; n < failed && d < preemptionMaxRetryBackoff; n++ {
+		d *= 2
+	}
+	if d > preemptionMaxRetryBackoff {
+		d = preemptionMaxRetryBackoff
+	}
+	return d
+}
+
+func isStoppedStatus(status string) bool {
+	return status == "STOPPING" || status == "STOPPED" || status == "TERMINATED"
+}
+
+// Reports whether GCP has recorded a preemption of the instance since the
+// watch started that hasn't been seen yet.
+func newPreemptionOperation(since time.Time, seen map[string]bool) (bool, error) {
+	found := false
+	err := gcpComputeService.ZoneOperations.List(gcpProjectId, gcpZone).
+		Filter(`operationType="compute.instances.preempted"`).
+		Pages(context.Background(), func(ops *compute.OperationList) error {
+			for _, op := range ops.Items {
+				inserted, err := time.Parse(time.RFC3339, op.InsertTime)
+				if err != nil || inserted.Before(since) || seen[op.Name] {
+					continue
+				}
+				if !strings.HasSuffix(op.TargetLink, "/instances/"+gcpServerName) {
+					continue
+				}
+				seen[op.Name] = true
+				found = true
+			}
+			return nil
+		})
+	if err != nil {
+		return false, fmt.Errorf("cannot list zone operations: %w", err)
+	}
+	return found, nil
+}
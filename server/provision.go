@@ -0,0 +1,314 @@
+package server
+
+import (
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+//go:embed provision/startup-script.sh
+var startupScript string
+
+// Port the MC server listens on. A var so tests can ping a fake server.
+var minecraftPort = "25565"
+
+const (
+	serverNetwork = "global/networks/default"
+	serverTag     = "minecraft-server"
+	bootImage     = "projects/ubuntu-os-cloud/global/images/family/ubuntu-2004-lts"
+
+	// Matches the device the startup script mounts.
+	dataDiskDeviceName = "mc-data"
+)
+
+var gcpMachineType string
+var dataDiskSizeGb int64
+var managementServerCertPath string
+var managementServerKeySecret string
+var managementSourceRanges []string
+var instanceServiceAccount string
+
+// Instance metadata attributes read by the startup script, set from the
+// environment variables of the same name when present.
+var provisionMetadata = map[string]string{
+	"minecraft-server-url":  "MINECRAFT_SERVER_URL",
+	"management-server-url": "MANAGEMENT_SERVER_URL",
+	"minecraft-memory":      "MINECRAFT_MEMORY",
+}
+
+// An instance without these can't run the MC server or the management
+// server.
+var requiredMetadata = []string{"minecraft-server-url", "management-server-url"}
+
+// Instance metadata attributes holding the management server's mTLS
+// certificate and the CA it checks the bot's certificate against, mapped to
+// the files they are read from. The startup script writes them to certs/ on
+// the data disk. The private key isn't among them, as anyone who can view
+// the instance can read its metadata; the startup script fetches it from
+// Secret Manager instead.
+func provisionCertFiles() map[string]string {
+	return map[string]string{
+		"management-server-cert": managementServerCertPath,
+		"management-ca-cert":     caCertPath,
+	}
+}
+
+// Returns the Secret Manager version holding the management server's
+// private key, given either a secret name in the bot's project or a full
+// resource name.
+func managementServerKeyVersion() string {
+	name := managementServerKeySecret
+	if !strings.HasPrefix(name, "projects/") {
+		name = fmt.Sprintf("projects/%v/secrets/%v", gcpProjectId, name)
+	}
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	return name
+}
+
+// Set up provisioning options, loading from environment where necessary
+func init() {
+	gcpMachineType = os.Getenv("GCP_MACHINE_TYPE")
+	if gcpMachineType == "" {
+		gcpMachineType = "e2-standard-2"
+	}
+	dataDiskSizeGb = int64(envInt("GCP_DATA_DISK_GB"))
+	if dataDiskSizeGb == 0 {
+		dataDiskSizeGb = 20
+	}
+	managementServerCertPath = os.Getenv("MANAGEMENT_SERVER_CERT_FILE")
+	if managementServerCertPath == "" {
+		managementServerCertPath = "certs/discord-mc-server.crt"
+	}
+	// The instance's service account has to be able to access this secret.
+	managementServerKeySecret = os.Getenv("MANAGEMENT_SERVER_KEY_SECRET")
+	instanceServiceAccount = os.Getenv("GCP_INSTANCE_SERVICE_ACCOUNT")
+	if instanceServiceAccount == "" {
+		instanceServiceAccount = "default"
+	}
+
+	// Where the bot connects to the management server from. Only the MC
+	// port is open to everyone.
+	for _, r := range strings.Split(os.Getenv("MANAGEMENT_SOURCE_RANGES"), ",") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(r); err != nil {
+			log.WithError(err).Fatal("Environment Variable MANAGEMENT_SOURCE_RANGES must be a list of CIDR ranges.")
+		}
+		managementSourceRanges = append(managementSourceRanges, r)
+	}
+}
+
+// Describes a new MC server instance: a boot disk for the OS, a separate
+// data disk for the world, an external IP, and a startup script that
+// installs Java, Minecraft and the management server. Fails with
+// ErrProvisioning if a required setting or certificate is missing.
+func instanceTemplate() (*compute.Instance, error) {
+	if managementServerKeySecret == "" {
+		return nil, fmt.Errorf("%w: environment variable MANAGEMENT_SERVER_KEY_SECRET must be set to create an instance", ErrProvisioning)
+	}
+
+	script := startupScript
+	metadata := []*compute.MetadataItems{
+		{Key: "startup-script", Value: &script},
+	}
+	set := make(map[string]bool)
+	for key, env := range provisionMetadata {
+		if v := os.Getenv(env); v != "" {
+			v := v
+			metadata = append(metadata, &compute.MetadataItems{Key: key, Value: &v})
+			set[key] = true
+		}
+	}
+	var missing []string
+	for _, key := range requiredMetadata {
+		if !set[key] {
+			missing = append(missing, provisionMetadata[key])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: environment variables %v must be set to create an instance", ErrProvisioning, strings.Join(missing, ", "))
+	}
+
+	for key, path := range provisionCertFiles() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: cannot read the management server's certificates: %v", ErrProvisioning, err)
+		}
+		v := string(data)
+		metadata = append(metadata, &compute.MetadataItems{Key: key, Value: &v})
+	}
+	keyVersion := managementServerKeyVersion()
+	metadata = append(metadata, &compute.MetadataItems{Key: "management-server-key-secret", Value: &keyVersion})
+
+	return &compute.Instance{
+		Name:        gcpServerName,
+		Description: "A server used by Houses United to play MC",
+		Zone:        gcpZone,
+		MachineType: fmt.Sprintf("zones/%v/machineTypes/%v", gcpZone, gcpMachineType),
+		Disks: []*compute.AttachedDisk{
+			{
+				AutoDelete: true,
+				Boot:       true,
+				Type:       "PERSISTENT",
+				InitializeParams: &compute.AttachedDiskInitializeParams{
+					DiskName:    gcpServerName + "-boot",
+					SourceImage: bootImage,
+				},
+			},
+			{
+				// The world outlives the instance.
+				AutoDelete: false,
+				DeviceName: dataDiskDeviceName,
+				Type:       "PERSISTENT",
+				InitializeParams: &compute.AttachedDiskInitializeParams{
+					DiskName:   gcpServerName + "-data",
+					DiskSizeGb: dataDiskSizeGb,
+				},
+			},
+		},
+		NetworkInterfaces: []*compute.NetworkInterface{
+			{
+				Network: serverNetwork,
+				AccessConfigs: []*compute.AccessConfig{
+					{
+						Name: "External NAT",
+						Type: "ONE_TO_ONE_NAT",
+					},
+				},
+			},
+		},
+		// Lets the startup script read the key from Secret Manager.
+		ServiceAccounts: []*compute.ServiceAccount{
+			{
+				Email:  instanceServiceAccount,
+				Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"},
+			},
+		},
+		Scheduling: instanceScheduling(),
+		Tags: &compute.Tags{
+			Items: []string{serverTag},
+		},
+		Metadata: &compute.Metadata{
+			Items: metadata,
+		},
+	}, nil
+}
+
+// Makes sure the firewall lets players reach instances tagged as MC
+// servers from anywhere, and the bot reach their management server from
+// MANAGEMENT_SOURCE_RANGES.
+func ensureFirewallRules() error {
+	if len(managementSourceRanges) == 0 {
+		return fmt.Errorf("%w: environment variable MANAGEMENT_SOURCE_RANGES must be set to create an instance", ErrProvisioning)
+	}
+
+	err := ensureFirewallRule(&compute.Firewall{
+		Name:        gcpServerName + "-allow-minecraft",
+		Description: "Allow Minecraft players",
+		Network:     serverNetwork,
+		Allowed: []*compute.FirewallAllowed{
+			{IPProtocol: "tcp", Ports: []string{minecraftPort}},
+		},
+		SourceRanges: []string{"0.0.0.0/0"},
+		TargetTags:   []string{serverTag},
+	})
+	if err != nil {
+		return err
+	}
+	return ensureFirewallRule(&compute.Firewall{
+		Name:        gcpServerName + "-allow-management",
+		Description: "Allow the bot to reach the management server",
+		Network:     serverNetwork,
+		Allowed: []*compute.FirewallAllowed{
+			{IPProtocol: "tcp", Ports: []string{managementServerPort}},
+		},
+		SourceRanges: managementSourceRanges,
+		TargetTags:   []string{serverTag},
+	})
+}
+
+// Creates the firewall rule, or updates it if it exists but allows other
+// ports or sources.
+func ensureFirewallRule(rule *compute.Firewall) error {
+	existing, err := gcpComputeService.Firewalls.Get(gcpProjectId, rule.Name).Do()
+	var op *compute.Operation
+	switch e, ok := err.(*googleapi.Error); {
+	case err == nil:
+		if firewallRuleMatches(existing, rule) {
+			return nil
+		}
+		log.Infof("Updating firewall rule %v", rule.Name)
+		op, err = gcpComputeService.Firewalls.Patch(gcpProjectId, rule.Name, rule).Do()
+	case ok && e.Code == 404:
+		log.Infof("Creating firewall rule %v", rule.Name)
+		op, err = gcpComputeService.Firewalls.Insert(gcpProjectId, rule).Do()
+	default:
+		return fmt.Errorf("cannot get firewall rule %v: %w", rule.Name, err)
+	}
+	if err != nil {
+		return fmt.Errorf("call to update firewall rule %v failed: %w", rule.Name, err)
+	}
+	err = waitForOperation(op)
+	if err != nil {
+		return fmt.Errorf("cannot update firewall rule %v: %w", rule.Name, err)
+	}
+	return nil
+}
+
+func firewallRuleMatches(existing, want *compute.Firewall) bool {
+	if len(existing.Allowed) != len(want.Allowed) || !reflect.DeepEqual(existing.SourceRanges, want.SourceRanges) {
+		return false
+	}
+	for n, a := range want.Allowed {
+		if existing.Allowed[n].IPProtocol != a.IPProtocol || !reflect.DeepEqual(existing.Allowed[n].Ports, a.Ports) {
+			return false
+		}
+	}
+	return true
+}
+
+// Creates the MC server instance from the template.
+func createInstance() error {
+	template, err := instanceTemplate()
+	if err != nil {
+		return err
+	}
+
+	err = ensureFirewallRules()
+	if err != nil {
+		return err
+	}
+
+	// Reuse the world from a previous instance if it's still around.
+	dataDisk := template.Disks[1]
+	_, err = gcpComputeService.Disks.Get(gcpProjectId, gcpZone, dataDisk.InitializeParams.DiskName).Do()
+	if err == nil {
+		log.Infof("Reusing existing data disk %v", dataDisk.InitializeParams.DiskName)
+		dataDisk.Source = fmt.Sprintf("zones/%v/disks/%v", gcpZone, dataDisk.InitializeParams.DiskName)
+		dataDisk.InitializeParams = nil
+	} else if e, ok := err.(*googleapi.Error); !ok || e.Code != 404 {
+		return fmt.Errorf("cannot get data disk details: %w", err)
+	}
+
+	opi, err := gcpComputeService.Instances.Insert(gcpProjectId, gcpZone, template).Do()
+	if err != nil {
+		return fmt.Errorf("call to create GCP instance failed: %w", err)
+	}
+	err = waitForOperation(opi)
+	if err != nil {
+		return fmt.Errorf("cannot create GCP instance: %w", err)
+	}
+	log.Infof("Instance id %v created\n", opi.TargetId)
+	return nil
+}
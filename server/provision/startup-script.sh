@@ -0,0 +1,131 @@
+#!/bin/bash
+# Startup script for the MC server instance. Runs as root on every boot.
+#
+# Settings are read from instance metadata attributes set by the bot:
+#   minecraft-server-url   URL of the Minecraft server jar
+#   management-server-url  URL of the management server binary
+#   minecraft-memory       Java heap size, e.g. 6G
+#   management-server-cert the management server's mTLS certificate,
+#   management-ca-cert     the CA that signed the bot's certificate,
+#   management-server-key-secret
+#                          and the Secret Manager version holding the
+#                          management server's private key, which is read
+#                          with the instance's service account
+#
+# The world, jars and the management server's certs (under certs/) live on
+# the data disk, mounted at /srv/minecraft, so they survive the boot disk
+# being recreated or restored.
+set -euo pipefail
+
+METADATA=http://metadata.google.internal/computeMetadata/v1/instance/attributes
+attr() {
+	curl -sf -H "Metadata-Flavor: Google" "$METADATA/$1" || true
+}
+
+DATA_DEVICE=/dev/disk/by-id/google-mc-data
+DATA_DIR=/srv/minecraft
+
+# Mount the data disk, formatting it the first time.
+if ! blkid "$DATA_DEVICE" >/dev/null; then
+	mkfs.ext4 -m 0 -E lazy_itable_init=0,lazy_journal_init=0,discard "$DATA_DEVICE"
+fi
+mkdir -p "$DATA_DIR"
+if ! mountpoint -q "$DATA_DIR"; then
+	mount -o discard,defaults "$DATA_DEVICE" "$DATA_DIR"
+fi
+grep -q "$DATA_DEVICE" /etc/fstab || echo "$DATA_DEVICE $DATA_DIR ext4 discard,defaults,nofail 0 2" >>/etc/fstab
+
+if ! command -v java >/dev/null; then
+	apt-get update
+	apt-get install -y openjdk-17-jre-headless
+fi
+
+id minecraft >/dev/null 2>&1 || useradd --system --home "$DATA_DIR" minecraft
+
+MINECRAFT_URL=$(attr minecraft-server-url)
+if [ ! -f "$DATA_DIR/server.jar" ] && [ -n "$MINECRAFT_URL" ]; then
+	curl -sfL -o "$DATA_DIR/server.jar" "$MINECRAFT_URL"
+fi
+echo "eula=true" >"$DATA_DIR/eula.txt"
+
+MANAGEMENT_URL=$(attr management-server-url)
+if [ ! -f "$DATA_DIR/mc-management" ] && [ -n "$MANAGEMENT_URL" ]; then
+	curl -sfL -o "$DATA_DIR/mc-management" "$MANAGEMENT_URL"
+	chmod +x "$DATA_DIR/mc-management"
+fi
+
+# Written on every boot, so that certificates rotated in metadata are
+# picked up by restarting the instance.
+mkdir -p "$DATA_DIR/certs"
+write_cert() {
+	local value
+	value=$(attr "$1")
+	if [ -n "$value" ]; then
+		(umask 077 && printf '%s\n' "$value" >"$DATA_DIR/certs/$2")
+	fi
+}
+write_cert management-server-cert discord-mc-server.crt
+write_cert management-ca-cert discord-mc.crt
+
+# The key stays where it is if it can't be fetched, e.g. because the
+# service account lost access to the secret.
+write_secret() {
+	local name token
+	name=$(attr "$1")
+	[ -n "$name" ] || return 0
+	token=$(curl -sf -H "Metadata-Flavor: Google" \
+		http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token |
+		python3 -c 'import json, sys; print(json.load(sys.stdin)["access_token"])') || true
+	if (umask 077 && curl -sf -H "Authorization: Bearer $token" "https://secretmanager.googleapis.com/v1/$name:access" |
+		python3 -c 'import base64, json, sys; sys.stdout.buffer.write(base64.b64decode(json.load(sys.stdin)["payload"]["data"]))' \
+			>"$DATA_DIR/certs/$2.new"); then
+		mv "$DATA_DIR/certs/$2.new" "$DATA_DIR/certs/$2"
+	else
+		echo "cannot read $name from Secret Manager" >&2
+		rm -f "$DATA_DIR/certs/$2.new"
+	fi
+}
+write_secret management-server-key-secret discord-mc-server.key
+
+chown -R minecraft:minecraft "$DATA_DIR"
+
+MEMORY=$(attr minecraft-memory)
+MEMORY=${MEMORY:-6G}
+
+cat >/etc/systemd/system/minecraft.service <<UNIT
+[Unit]
+Description=Minecraft server
+After=network-online.target
+
+[Service]
+User=minecraft
+WorkingDirectory=$DATA_DIR
+ExecStart=/usr/bin/java -Xms$MEMORY -Xmx$MEMORY -jar server.jar nogui
+Restart=on-failure
+
+[Install]
+WantedBy=multi-user.target
+UNIT
+
+cat >/etc/systemd/system/mc-management.service <<UNIT
+[Unit]
+Description=Minecraft management server
+After=minecraft.service
+
+[Service]
+User=minecraft
+WorkingDirectory=$DATA_DIR
+ExecStart=$DATA_DIR/mc-management
+Restart=always
+
+[Install]
+WantedBy=multi-user.target
+UNIT
+
+systemctl daemon-reload
+if [ -f "$DATA_DIR/server.jar" ]; then
+	systemctl enable --now minecraft.service
+fi
+if [ -x "$DATA_DIR/mc-management" ]; then
+	systemctl enable --now mc-management.service
+fi
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setEnv(t *testing.T, key, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv(key)
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestInstanceTemplate(t *testing.T) {
+	// The CA is always read from certs/ in the working directory.
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+	os.Mkdir("certs", 0700)
+	ioutil.WriteFile(caCertPath, []byte("ca"), 0600)
+
+	defer func(cert, secret, project string) {
+		managementServerCertPath, managementServerKeySecret, gcpProjectId = cert, secret, project
+	}(managementServerCertPath, managementServerKeySecret, gcpProjectId)
+	managementServerCertPath = filepath.Join(dir, "server.crt")
+	ioutil.WriteFile(managementServerCertPath, []byte("cert"), 0600)
+	gcpProjectId = "project"
+
+	tests := []struct {
+		name          string
+		minecraftURL  string
+		managementURL string
+		keySecret     string
+		wantSecret    string
+		wantErr       bool
+	}{
+		{name: "no urls", keySecret: "key", wantErr: true},
+		{name: "no management server url", minecraftURL: "https://example.com/server.jar", keySecret: "key", wantErr: true},
+		{name: "no minecraft url", managementURL: "https://example.com/mc-management", keySecret: "key", wantErr: true},
+		{name: "no key secret", minecraftURL: "https://example.com/server.jar", managementURL: "https://example.com/mc-management", wantErr: true},
+		{
+			name:          "complete",
+			minecraftURL:  "https://example.com/server.jar",
+			managementURL: "https://example.com/mc-management",
+			keySecret:     "key",
+			wantSecret:    "projects/project/secrets/key/versions/latest",
+		},
+		{
+			name:          "secret in another project",
+			minecraftURL:  "https://example.com/server.jar",
+			managementURL: "https://example.com/mc-management",
+			keySecret:     "projects/other/secrets/key/versions/3",
+			wantSecret:    "projects/other/secrets/key/versions/3",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setEnv(t, "MINECRAFT_SERVER_URL", test.minecraftURL)
+			setEnv(t, "MANAGEMENT_SERVER_URL", test.managementURL)
+			managementServerKeySecret = test.keySecret
+
+			instance, err := instanceTemplate()
+			if test.wantErr {
+				if !errors.Is(err, ErrProvisioning) {
+					t.Errorf("got %v, want ErrProvisioning", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			metadata := make(map[string]string)
+			for _, item := range instance.Metadata.Items {
+				metadata[item.Key] = *item.Value
+			}
+			want := map[string]string{
+				"minecraft-server-url":         test.minecraftURL,
+				"management-server-url":        test.managementURL,
+				"management-server-cert":       "cert",
+				"management-ca-cert":           "ca",
+				"management-server-key-secret": test.wantSecret,
+			}
+			for key, value := range want {
+				if metadata[key] != value {
+					t.Errorf("metadata %v = %q, want %q", key, metadata[key], value)
+				}
+			}
+			if metadata["startup-script"] == "" {
+				t.Error("no startup script")
+			}
+			if _, ok := metadata["management-server-key"]; ok {
+				t.Error("private key in metadata")
+			}
+		})
+	}
+}
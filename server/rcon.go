@@ -0,0 +1,316 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	pb "github.com/mirrorkeydev/discord-mc-bot/proto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RCON packet types, see https://wiki.vg/RCON
+const (
+	rconTypeResponse = 0
+	rconTypeCommand  = 2
+	rconTypeLogin    = 3
+	// Not a real type; the MC server answers it with an error, which marks
+	// the end of the response to the command sent before it.
+	rconTypeEnd = 100
+)
+
+const (
+	rconTimeout       = 10 * time.Second
+	rconMaxPacketSize = 4110
+)
+
+var (
+	// ErrRCONUnavailable is returned when RCON isn't configured or the MC
+	// server can't be reached over it.
+	ErrRCONUnavailable = errors.New("rcon unavailable")
+	// ErrRCONAuth is returned when the MC server rejects the RCON password.
+	ErrRCONAuth = errors.New("rcon authentication failed")
+)
+
+var rconHost string
+var rconPort string
+var rconPassword string
+
+// Set up RCON, loading from environment where necessary. RCON is only used
+// when RCON_HOST and RCON_PASSWORD are set.
+//
+// RCON sends the password in plain text, so the firewall doesn't open the
+// RCON port to the internet. RCON_HOST has to reach the instance privately,
+// e.g. its internal IP from inside the VPC, or the local end of an SSH
+// tunnel.
+func init() {
+	rconPassword = os.Getenv("RCON_PASSWORD")
+	rconHost = os.Getenv("RCON_HOST")
+	if (rconHost == "") != (rconPassword == "") {
+		log.Fatal("Environment Variables RCON_HOST and RCON_PASSWORD must both be set to use RCON.")
+	}
+	rconPort = os.Getenv("RCON_PORT")
+	if rconPort == "" {
+		rconPort = "25575"
+	}
+}
+
+func rconConfigured() bool {
+	return rconHost != "" && rconPassword != ""
+}
+
+func rconAddress() string {
+	return net.JoinHostPort(rconHost, rconPort)
+}
+
+// A logged in connection to the MC server's RCON port. It talks to
+// Minecraft directly, so it keeps working when the management server is
+// down.
+type rconClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID int32
+}
+
+func dialRCON(ctx context.Context) (*rconClient, error) {
+	if !rconConfigured() {
+		return nil, fmt.Errorf("%w: RCON_HOST and RCON_PASSWORD are not set", ErrRCONUnavailable)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", rconAddress())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRCONUnavailable, err)
+	}
+	c := &rconClient{conn: conn, reader: bufio.NewReader(conn)}
+
+	id, err := c.send(rconTypeLogin, rconPassword)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	respID, _, err := c.read()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if respID != id {
+		c.Close()
+		return nil, ErrRCONAuth
+	}
+	return c, nil
+}
+
+// Runs a console command, returning its output.
+func (c *rconClient) Command(command string) (string, error) {
+	id, err := c.send(rconTypeCommand, command)
+	if err != nil {
+		return "", err
+	}
+	endID, err := c.send(rconTypeEnd, "")
+	if err != nil {
+		return "", err
+	}
+
+	// Long outputs are split over several packets, so keep reading until
+	// the reply to the end marker shows up.
+	var out strings.Builder
+	for {
+		respID, body, err := c.read()
+		if err != nil {
+			return "", err
+		}
+		switch respID {
+		case id:
+			out.WriteString(body)
+		case endID:
+			return out.String(), nil
+		}
+	}
+}
+
+func (c *rconClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *rconClient) send(packetType int32, body string) (int32, error) {
+	c.nextID++
+	id := c.nextID
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, int32(4+4+len(body)+2))
+	binary.Write(&buf, binary.LittleEndian, id)
+	binary.Write(&buf, binary.LittleEndian, packetType)
+	buf.WriteString(body)
+	buf.Write([]byte{0, 0})
+
+	c.conn.SetWriteDeadline(time.Now().Add(rconTimeout))
+	_, err := c.conn.Write(buf.Bytes())
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrRCONUnavailable, err)
+	}
+	return id, nil
+}
+
+func (c *rconClient) read() (id int32, body string, err error) {
+	c.conn.SetReadDeadline(time.Now().Add(rconTimeout))
+
+	var length int32
+	err = binary.Read(c.reader, binary.LittleEndian, &length)
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %v", ErrRCONUnavailable, err)
+	}
+	if length < 10 || length > rconMaxPacketSize {
+		return 0, "", fmt.Errorf("%w: bad packet length %v", ErrRCONUnavailable, length)
+	}
+	packet := make([]byte, length)
+	_, err = io.ReadFull(c.reader, packet)
+	if err != nil {
+		return 0, "", fmt.Errorf("%w: %v", ErrRCONUnavailable, err)
+	}
+
+	id = int32(binary.LittleEndian.Uint32(packet[0:4]))
+	if packetType := int32(binary.LittleEndian.Uint32(packet[4:8])); packetType != rconTypeResponse && packetType != rconTypeCommand {
+		return 0, "", fmt.Errorf("%w: unexpected packet type %v", ErrRCONUnavailable, packetType)
+	}
+	return id, string(bytes.TrimRight(packet[8:], "\x00")), nil
+}
+
+// Runs fallback when err says the management server is unavailable, or is
+// the status returned by an RPC it doesn't implement, and RCON is
+// configured. If that fails as well, both errors are reported and err can
+// still be matched.
+func withRCONFallback(err error, fallback func() error) error {
+	if !errors.Is(err, ErrManagementUnavailable) && status.Code(err) != codes.Unimplemented {
+		return err
+	}
+	if !rconConfigured() {
+		return err
+	}
+	log.WithError(err).Info("Management server unavailable, falling back to RCON. ")
+	rconErr := fallback()
+	if rconErr == nil {
+		return nil
+	}
+	var wErr *WhitelistError
+	if errors.As(rconErr, &wErr) {
+		return rconErr
+	}
+	return fmt.Errorf("%w (rcon: %v)", err, rconErr)
+}
+
+// Runs a single console command over RCON, returning its output.
+func rconCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rconTimeout)
+	defer cancel()
+
+	c, err := dialRCON(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	return c.Command(command)
+}
+
+// Adds a player to the whitelist over RCON, reporting the outcome the same
+// way the management server does.
+func rconWhitelist(user string) error {
+	// The name goes straight into a console command.
+	if !ValidMinecraftName(user) {
+		return &WhitelistError{Code: pb.UpdateWhitelistResponse_INVAL_MC_USER, Response: fmt.Sprintf("%q isn't a valid username", user)}
+	}
+	out, err := rconCommand("whitelist add " + user)
+	if err != nil {
+		return fmt.Errorf("could not whitelist %v: %w", user, err)
+	}
+	switch {
+	case strings.HasPrefix(out, "Added"):
+		return nil
+	case strings.Contains(out, "already whitelisted"):
+		return &WhitelistError{Code: pb.UpdateWhitelistResponse_DUP_ADD, Response: out}
+	case strings.Contains(out, "does not exist"):
+		return &WhitelistError{Code: pb.UpdateWhitelistResponse_INVAL_MC_USER, Response: out}
+	default:
+		return &WhitelistError{Code: pb.UpdateWhitelistResponse_UNKNOWN, Response: out}
+	}
+}
+
+// e.g. "There are 2 of a max of 20 players online: Alex, Steve"
+var rconListPattern = regexp.MustCompile(`There are (\d+) of a max(?: of)? \d+ players online:\s*(.*)`)
+
+// Lists the players online over RCON.
+func rconPlayers() ([]string, error) {
+	out, err := rconCommand("list")
+	if err != nil {
+		return nil, err
+	}
+	m := rconListPattern.FindStringSubmatch(out)
+	if m == nil {
+		return nil, fmt.Errorf("unexpected response to list: %q", out)
+	}
+	var players []string
+	for _, p := range strings.Split(m[2], ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			players = append(players, p)
+		}
+	}
+	return players, nil
+}
+
+// Saves the world and stops the MC server over RCON, then waits for it to
+// exit.
+func rconStop(ctx context.Context) error {
+	c, err := dialRCON(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	_, err = c.Command("save-all flush")
+	if err != nil {
+		return fmt.Errorf("could not save the world: %w", err)
+	}
+	// The MC server may hang up before it gets around to replying.
+	_, err = c.Command("stop")
+	if err != nil {
+		log.WithError(err).Debug("no reply to stop over rcon")
+	}
+
+	for !minecraftStopped(ctx) {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("MC server did not stop: %w", ctx.Err())
+		case <-time.After(time.Second):
+		}
+	}
+	log.Info("MC server saved the world and stopped. ")
+	return nil
+}
+
+// Reports whether the MC server has exited, i.e. it no longer accepts RCON
+// logins and doesn't answer a server list ping. The RCON port refusing
+// connections isn't enough on its own, as a tunnel to it keeps accepting
+// them.
+func minecraftStopped(ctx context.Context) bool {
+	c, err := dialRCON(ctx)
+	if err == nil {
+		c.Close()
+		return false
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	_, err = pingMinecraft(ctx)
+	return err != nil
+}
@@ -0,0 +1,338 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/mirrorkeydev/discord-mc-bot/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A stand-in for the MC server's RCON port. Each command is answered with
+// the packets listed for it in replies; unknown commands get an empty reply.
+type fakeRCON struct {
+	listener net.Listener
+	password string
+	replies  map[string][]string
+	// Closes the listener this long after the stop command.
+	stopDelay time.Duration
+	// Like an SSH tunnel, keep accepting connections after the stop but
+	// hang up on them straight away.
+	tunnel bool
+
+	mutex    sync.Mutex
+	commands []string
+	exited   bool
+	stopped  chan struct{}
+}
+
+func startFakeRCON(t *testing.T, replies map[string][]string) *fakeRCON {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRCON{
+		listener: l,
+		password: "hunter2",
+		replies:  replies,
+		stopped:  make(chan struct{}),
+	}
+	go f.serve()
+
+	// Nothing answers server list pings.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	oldHost, oldPort, oldPassword := rconHost, rconPort, rconPassword
+	oldAddress, oldMinecraftPort := ManagementServerAddress, minecraftPort
+	rconHost, rconPort, _ = net.SplitHostPort(l.Addr().String())
+	rconPassword = f.password
+	ManagementServerAddress, minecraftPort, _ = net.SplitHostPort(closed.Addr().String())
+	t.Cleanup(func() {
+		rconHost, rconPort, rconPassword = oldHost, oldPort, oldPassword
+		ManagementServerAddress, minecraftPort = oldAddress, oldMinecraftPort
+		l.Close()
+	})
+	return f
+}
+
+func (f *fakeRCON) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRCON) handle(conn net.Conn) {
+	defer conn.Close()
+	f.mutex.Lock()
+	exited := f.exited
+	f.mutex.Unlock()
+	if exited {
+		return
+	}
+	r := bufio.NewReader(conn)
+	for {
+		var length int32
+		if binary.Read(r, binary.LittleEndian, &length) != nil {
+			return
+		}
+		packet := make([]byte, length)
+		if _, err := io.ReadFull(r, packet); err != nil {
+			return
+		}
+		id := int32(binary.LittleEndian.Uint32(packet[0:4]))
+		packetType := int32(binary.LittleEndian.Uint32(packet[4:8]))
+		body := string(bytes.TrimRight(packet[8:], "\x00"))
+
+		switch packetType {
+		case rconTypeLogin:
+			if body != f.password {
+				id = -1
+			}
+			writeRCONPacket(conn, id, rconTypeCommand, "")
+		case rconTypeCommand:
+			f.mutex.Lock()
+			f.commands = append(f.commands, body)
+			f.mutex.Unlock()
+			for _, part := range f.replies[body] {
+				writeRCONPacket(conn, id, rconTypeResponse, part)
+			}
+			if body == "stop" {
+				// Like the MC server, hang up without answering the end
+				// marker, then close the port once it has exited.
+				time.AfterFunc(f.stopDelay, func() {
+					f.mutex.Lock()
+					f.exited = true
+					f.mutex.Unlock()
+					if !f.tunnel {
+						f.listener.Close()
+					}
+					close(f.stopped)
+				})
+				return
+			}
+		default:
+			writeRCONPacket(conn, id, rconTypeResponse, "Unknown request 64")
+		}
+	}
+}
+
+func writeRCONPacket(w io.Writer, id int32, packetType int32, body string) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, int32(4+4+len(body)+2))
+	binary.Write(&buf, binary.LittleEndian, id)
+	binary.Write(&buf, binary.LittleEndian, packetType)
+	buf.WriteString(body)
+	buf.Write([]byte{0, 0})
+	w.Write(buf.Bytes())
+}
+
+func (f *fakeRCON) received() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+func TestRCONLogin(t *testing.T) {
+	startFakeRCON(t, nil)
+
+	c, err := dialRCON(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	rconPassword = "wrong"
+	_, err = dialRCON(context.Background())
+	if !errors.Is(err, ErrRCONAuth) {
+		t.Errorf("got %v, want ErrRCONAuth", err)
+	}
+}
+
+func TestRCONNotConfigured(t *testing.T) {
+	startFakeRCON(t, nil)
+	rconHost = ""
+
+	_, err := rconCommand("list")
+	if !errors.Is(err, ErrRCONUnavailable) {
+		t.Errorf("got %v, want ErrRCONUnavailable", err)
+	}
+
+	mgmtErr := ErrManagementUnavailable
+	called := false
+	err = withRCONFallback(mgmtErr, func() error {
+		called = true
+		return nil
+	})
+	if called || err != mgmtErr {
+		t.Errorf("fallback ran without RCON configured, got %v", err)
+	}
+}
+
+func TestRCONFallback(t *testing.T) {
+	startFakeRCON(t, nil)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "unavailable", err: fmt.Errorf("%w: timed out", ErrManagementUnavailable), want: true},
+		{name: "unimplemented", err: status.Error(codes.Unimplemented, "unknown method RunCommand"), want: true},
+		{name: "other rpc error", err: status.Error(codes.Internal, "broken"), want: false},
+	}
+	for _, test := range tests {
+		called := false
+		err := withRCONFallback(test.err, func() error {
+			called = true
+			return nil
+		})
+		if called != test.want {
+			t.Errorf("%v: fallback ran = %v, want %v", test.name, called, test.want)
+		}
+		if !test.want && err != test.err {
+			t.Errorf("%v: got %v, want the original error", test.name, err)
+		}
+	}
+}
+
+func TestRCONSplitReply(t *testing.T) {
+	startFakeRCON(t, map[string][]string{
+		"help": {"first part, ", "second part, ", "last part"},
+	})
+
+	out, err := rconCommand("help")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "first part, second part, last part"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestRCONWhitelist(t *testing.T) {
+	f := startFakeRCON(t, map[string][]string{
+		"whitelist add Steve":  {"Added Steve to the whitelist"},
+		"whitelist add Alex":   {"Player is already whitelisted"},
+		"whitelist add nobody": {"That player does not exist"},
+		"whitelist add Notch":  {"Something unexpected"},
+	})
+
+	tests := []struct {
+		user string
+		// Set when no error is expected, otherwise code is.
+		ok   bool
+		code pb.UpdateWhitelistResponse_WhitelistResult
+	}{
+		{user: "Steve", ok: true},
+		{user: "Alex", code: pb.UpdateWhitelistResponse_DUP_ADD},
+		{user: "nobody", code: pb.UpdateWhitelistResponse_INVAL_MC_USER},
+		{user: "Notch", code: pb.UpdateWhitelistResponse_UNKNOWN},
+		{user: "foo; op bar", code: pb.UpdateWhitelistResponse_INVAL_MC_USER},
+		{user: "Steve\nop Steve", code: pb.UpdateWhitelistResponse_INVAL_MC_USER},
+	}
+	for _, test := range tests {
+		err := rconWhitelist(test.user)
+		if test.ok {
+			if err != nil {
+				t.Errorf("%v: %v", test.user, err)
+			}
+			continue
+		}
+		var wErr *WhitelistError
+		if !errors.As(err, &wErr) || wErr.Code != test.code {
+			t.Errorf("%v: got %v, want a %v WhitelistError", test.user, err, test.code)
+		}
+	}
+	if got, want := len(f.received()), 4; got != want {
+		t.Errorf("sent %v commands, want %v: %q", got, want, f.received())
+	}
+}
+
+func TestRCONPlayers(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  []string
+	}{
+		{name: "nobody", reply: "There are 0 of a max of 20 players online: ", want: nil},
+		{name: "one", reply: "There are 1 of a max of 20 players online: Steve", want: []string{"Steve"}},
+		{name: "several", reply: "There are 3 of a max of 20 players online: Alex, Steve, Notch", want: []string{"Alex", "Steve", "Notch"}},
+		{name: "older format", reply: "There are 2 of a max 20 players online: Alex, Steve", want: []string{"Alex", "Steve"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			startFakeRCON(t, map[string][]string{"list": {test.reply}})
+
+			got, err := rconPlayers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	startFakeRCON(t, map[string][]string{"list": {"Unknown command"}})
+	if _, err := rconPlayers(); err == nil {
+		t.Error("expected an error for an unexpected reply")
+	}
+}
+
+func TestRCONStop(t *testing.T) {
+	for _, tunnel := range []bool{false, true} {
+		f := startFakeRCON(t, map[string][]string{
+			"save-all flush": {"Saved the game"},
+			"stop":           {"Stopping the server"},
+		})
+		f.stopDelay = 1500 * time.Millisecond
+		f.tunnel = tunnel
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := rconStop(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("tunnel %v: %v", tunnel, err)
+		}
+
+		select {
+		case <-f.stopped:
+		default:
+			t.Errorf("tunnel %v: rconStop returned before the MC server exited", tunnel)
+		}
+		if got, want := f.received(), []string{"save-all flush", "stop"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("tunnel %v: commands = %q, want %q", tunnel, got, want)
+		}
+	}
+}
+
+func TestRCONStopTimeout(t *testing.T) {
+	f := startFakeRCON(t, nil)
+	f.stopDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	err := rconStop(ctx)
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want a deadline error", err)
+	}
+}
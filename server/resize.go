@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"path"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// Checks that the machine type is available in the instance's zone.
+func ValidateMachineType(machineType string) error {
+	_, err := gcpComputeService.MachineTypes.Get(gcpProjectId, gcpZone, machineType).Do()
+	if err != nil {
+		if e, ok := err.(*googleapi.Error); ok && (e.Code == 404 || e.Code == 400) {
+			return fmt.Errorf("%w: %v in %v", ErrInvalidMachineType, machineType, gcpZone)
+		}
+		return fmt.Errorf("cannot get machine type details: %w", err)
+	}
+	markGCPSuccess()
+	return nil
+}
+
+// Reports whether resizing an instance in the given status means stopping
+// it first.
+func ResizeStopsServer(status string) bool {
+	return status != "STOPPED" && status != "TERMINATED"
+}
+
+// Changes the instance's machine type, stopping it first if necessary, and
+// starts it again afterwards if start is set. progress is called as each
+// step begins.
+func ResizeServer(machineType string, start bool, progress func(Phase)) error {
+	err := ValidateMachineType(machineType)
+	if err != nil {
+		return err
+	}
+
+	instance, err := getInstance()
+	if err != nil {
+		return err
+	}
+
+	if path.Base(instance.MachineType) == machineType {
+		log.Infof("Instance is already a %v, not resizing", machineType)
+	} else {
+		if ResizeStopsServer(instance.Status) {
+			_, err = BringDownServer(progress)
+			if err != nil {
+				return err
+			}
+		}
+
+		progress(PhaseResizing)
+		log.Infof("Resizing instance from %v to %v", path.Base(instance.MachineType), machineType)
+		op, err := gcpComputeService.Instances.SetMachineType(gcpProjectId, gcpZone, gcpServerName, &compute.InstancesSetMachineTypeRequest{
+			MachineType: fmt.Sprintf("zones/%v/machineTypes/%v", gcpZone, machineType),
+		}).Do()
+		if err != nil {
+			return fmt.Errorf("call to set the machine type failed: %w", err)
+		}
+		err = waitForOperation(op)
+		if err != nil {
+			return fmt.Errorf("cannot set the machine type: %w", err)
+		}
+	}
+
+	if start {
+		progress(PhaseStarting)
+		_, err = BringUpServer()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
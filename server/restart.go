@@ -0,0 +1,49 @@
+package server
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Stops the instance and starts it again, going through the same steps as
+// BringDownServer and BringUpServer. progress is called as each step
+// begins.
+func RestartServer(progress func(Phase)) (UpResult, error) {
+	_, err := BringDownServer(progress)
+	if err != nil {
+		return 0, err
+	}
+
+	progress(PhaseStarting)
+	return BringUpServer()
+}
+
+// Hard resets the instance, like pressing the reset button on a frozen
+// machine. Nothing is shut down gracefully, so this is a last resort.
+func ResetServer(progress func(Phase)) error {
+	instance, err := getInstance()
+	if err != nil {
+		return err
+	}
+	if instance.Status != "RUNNING" {
+		return fmt.Errorf("%w: status %v", ErrNotRunning, instance.Status)
+	}
+
+	progress(PhaseResetting)
+	log.Info("Resetting instance. ")
+	// So the preemption watcher doesn't take the reset for a preemption.
+	expectStop()
+	management.Close()
+
+	ops, err := gcpComputeService.Instances.Reset(gcpProjectId, gcpZone, gcpServerName).Do()
+	if err != nil {
+		return fmt.Errorf("call to reset the instance failed: %w", err)
+	}
+	err = waitForOperation(ops)
+	if err != nil {
+		return fmt.Errorf("cannot reset GCP instance: %w", err)
+	}
+	log.Info("Instance reset!")
+	return nil
+}
@@ -6,30 +6,25 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
 	"time"
 
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
 	pb "github.com/mirrorkeydev/discord-mc-bot/proto"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/oauth2/google"
-	"golang.org/x/oauth2/jwt"
 	"google.golang.org/api/compute/v1"
-	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-var gcpClientEmail string
 var gcpComputeService *compute.Service
 var gcpProjectId string
 var gcpServerName string
 var gcpZone string
 
 var ManagementServerAddress string
-var managementServerClient pb.MCManagementClient
-var managementServerConnection *grpc.ClientConn
 var managementServerPort string
 
 // Set up variables, loading from environment where necessary
@@ -40,137 +35,108 @@ func init() {
 
 	ManagementServerAddress = "garage.prototypical.pro"
 	managementServerPort = "50051"
-
-	gcpClientEmail = os.Getenv("CLIENT_EMAIL")
-	if gcpClientEmail == "" {
-		log.Fatal("Environment Variable CLIENT_EMAIL not set.")
-	}
 }
 
-// Set up connection to GCP compute service
-func init() {
-	privatekey, err := os.ReadFile("./certs/google-private-key.txt")
+// Sets up the connection to the GCP compute service. Must be called before
+// anything that manages the instance.
+func ConnectCompute() error {
+	ctx := context.Background()
+	tokenSource, err := gcpTokenSource(ctx)
 	if err != nil {
-		log.WithError(err).Fatal("unable to read google private key from file")
+		return fmt.Errorf("cannot authenticate with GCP: %w", err)
 	}
-
-	conf := &jwt.Config{
-		Email:      gcpClientEmail,
-		PrivateKey: privatekey,
-		Scopes: []string{
-			"https://www.googleapis.com/auth/compute",
-		},
-		TokenURL: google.JWTTokenURL,
+	// Credentials are only used on the first call otherwise, so a bad key
+	// would show up as every command failing.
+	_, err = tokenSource.Token()
+	if err != nil {
+		return fmt.Errorf("cannot get a GCP access token: %w", err)
 	}
 
-	httpClient := conf.Client(context.Background())
-	gcpComputeService, err = compute.NewService(context.Background(), option.WithHTTPClient(httpClient))
+	gcpComputeService, err = compute.NewService(ctx, option.WithTokenSource(tokenSource))
 	if err != nil {
-		log.WithError(err).Fatal("cannot create the compute service. ")
+		return fmt.Errorf("cannot create the compute service: %w", err)
 	}
 	log.Info("Compute service is ready!")
+	return nil
 }
 
-func BringUpServer() (bool, string) {
-	instance, err := gcpComputeService.Instances.Get(gcpProjectId, gcpZone, gcpServerName).Do()
-	if err != nil {
-		if e, ok := err.(*googleapi.Error); ok {
-			if e.Code == 404 {
-				log.Info("No VM instance available. Creating one now... ")
-
-				instanceOptions := compute.Instance{
-					Name:        gcpServerName,
-					Description: "A server used by Houses United to play MC",
-					Zone:        gcpZone,
-					MachineType: "zones/us-west1-a/machineTypes/e2-standard-2",
-					Disks: []*compute.AttachedDisk{
-						{
-							AutoDelete: true,
-							Boot:       true,
-							Type:       "PERSISTENT",
-							InitializeParams: &compute.AttachedDiskInitializeParams{
-								DiskName:    "my-root-pd",
-								SourceImage: "projects/ubuntu-os-cloud/global/images/ubuntu-2004-focal-v20210610",
-							},
-						},
-					},
-					NetworkInterfaces: []*compute.NetworkInterface{{}},
-				}
-				opi, err := gcpComputeService.Instances.Insert(gcpProjectId, gcpZone, &instanceOptions).Do()
-				if err != nil {
-					log.Info("Call to create GCP instance failed. ", err)
-					return false, "failed"
-				}
-				err = waitForOperation(opi)
-				if err != nil {
-					log.Info("Cannot create GCP instance. ", err)
-					return false, "failed"
-				}
-				log.Infof("Instance id %v created\n", opi.TargetId)
-				instance, err = gcpComputeService.Instances.Get(gcpProjectId, gcpZone, gcpServerName).Do()
-				if err != nil {
-					log.Info("Cannot get instance details. ", err)
-					return false, "failed"
-				}
-			} else {
-				log.Info("Cannot get available instances. ", err)
-				return false, "failed"
-			}
-		} else {
-			log.Info("Cannot get available instances. ", err)
-			return false, "failed"
+// Brings the instance up, creating it if it doesn't exist and resuming it
+// if it is suspended. Once it is
+// running, its DNS record is updated; if that fails the instance is still
+// up, and the returned error wraps ErrDNSUpdate.
+func BringUpServer() (UpResult, error) {
+	created := false
+	instance, err := getInstance()
+	if errors.Is(err, ErrNotFound) {
+		log.Info("No VM instance available. Creating one now... ")
+
+		err := createInstance()
+		if err != nil {
+			return 0, err
+		}
+		created = true
+		instance, err = getInstance()
+		if err != nil {
+			return 0, err
 		}
+	} else if err != nil {
+		return 0, err
 	}
 
 	for {
 		switch instance.Status {
 		case "RUNNING":
+			if created {
+				return UpCreated, updateDNS()
+			}
 			log.Info("Instance was already running, doing nothing. ")
-			return true, "instance was already running :clown:"
+			return UpAlreadyRunning, updateDNS()
 		case "STOPPED", "TERMINATED":
 			log.Info("Instance was stopped, trying to start it now. ")
 			ops, err := gcpComputeService.Instances.Start(gcpProjectId, gcpZone, gcpServerName).Do()
 			if err != nil {
-				log.Info("Call to start the instance failed. ", err)
-				return false, "failed"
+				return 0, fmt.Errorf("call to start the instance failed: %w", err)
 			}
 			err = waitForOperation(ops)
 			if err != nil {
-				log.Info("Cannot start GCP instance. ", err)
-				return false, "failed"
+				return 0, fmt.Errorf("cannot start GCP instance: %w", err)
 			}
 			log.Info("Instance started!")
-			return true, "done! please go do something else for 5 minutes, the server instance is booting up Minecraft"
-		case "PROVISIONING", "DEPROVISIONING", "REPAIRING", "STAGING", "STOPPING":
+			if created {
+				return UpCreated, updateDNS()
+			}
+			return UpStarted, updateDNS()
+		case "SUSPENDED":
+			log.Info("Instance was suspended, trying to resume it now. ")
+			ops, err := gcpComputeService.Instances.Resume(gcpProjectId, gcpZone, gcpServerName).Do()
+			if err != nil {
+				return 0, fmt.Errorf("call to resume the instance failed: %w", err)
+			}
+			err = waitForOperation(ops)
+			if err != nil {
+				return 0, fmt.Errorf("cannot resume GCP instance: %w", err)
+			}
+			log.Info("Instance resumed!")
+			return UpResumed, updateDNS()
+		case "PROVISIONING", "DEPROVISIONING", "REPAIRING", "STAGING", "STOPPING", "SUSPENDING":
 			log.Infof("Instance is in transitional status: %v, waiting 5 seconds and then seeing if anything changes \n", instance.Status)
 			time.Sleep(time.Second * 5)
-			instance, err = gcpComputeService.Instances.Get(gcpProjectId, gcpZone, gcpServerName).Do()
+			instance, err = getInstance()
 			if err != nil {
-				log.Info("Cannot get instance details. ", err)
-				return false, "failed"
+				return 0, err
 			}
-		case "SUSPENDED", "SUSPENDING":
-			log.Infof("Instance is in suspended (sleep) status: %v.\n", instance.Status)
-			return false, "server is suspended <&776313105788829727>"
 		}
 	}
 }
 
-func BringDownServer() (bool, string) {
-	instance, err := gcpComputeService.Instances.Get(gcpProjectId, gcpZone, gcpServerName).Do()
+// Brings the instance down. The MC server is asked to save the world and
+// exit first; if it doesn't manage that within the timeout the instance is
+// stopped anyway and DownStoppedUnclean is returned. progress is called as
+// each step begins.
+func BringDownServer(progress func(Phase)) (DownResult, error) {
+	instance, err := getInstance()
 	if err != nil {
-		if e, ok := err.(*googleapi.Error); ok {
-			if e.Code == 404 {
-				log.Info("Server already doesn't exist.")
-				return true, "it already didn't exist"
-			} else {
-				log.Info("Cannot get available instances. ", err)
-				return false, "failed"
-			}
-		} else {
-			log.Info("Cannot get available instances. ", err)
-			return false, "failed"
-		}
+		return 0, err
 	}
 
 	for {
@@ -178,63 +144,177 @@ func BringDownServer() (bool, string) {
 		case "RUNNING":
 			log.Info("Instance was running, trying to stop it now. ")
 
-			if managementServerConnection != nil {
-				managementServerConnection.Close()
+			expectStop()
+			result := DownStopped
+			err := stopMinecraft(progress)
+			if err != nil {
+				log.WithError(err).Warn("MC server did not stop cleanly, stopping the instance anyway")
+				result = DownStoppedUnclean
 			}
+			management.Close()
 
+			progress(PhaseStopping)
 			ops, err := gcpComputeService.Instances.Stop(gcpProjectId, gcpZone, gcpServerName).Do()
 			if err != nil {
-				log.Info("Call to stop the instance failed. ", err)
-				return false, "failed"
+				return 0, fmt.Errorf("call to stop the instance failed: %w", err)
 			}
 			err = waitForOperation(ops)
 			if err != nil {
-				log.Info("Cannot stop GCP instance. ", err)
-				return false, "failed"
+				return 0, fmt.Errorf("cannot stop GCP instance: %w", err)
 			}
 			log.Info("Instance stopped!")
-			return true, "done!"
+			return result, nil
 		case "STOPPED", "TERMINATED":
 			log.Info("Instance was already stopped, doing nothing. ")
-			return true, "it was already stopped!"
-		case "PROVISIONING", "DEPROVISIONING", "REPAIRING", "STAGING", "STOPPING":
+			return DownAlreadyStopped, nil
+		case "PROVISIONING", "DEPROVISIONING", "REPAIRING", "STAGING", "STOPPING", "SUSPENDING":
 			log.Infof("Instance is in transitional status: %v, waiting 5 seconds and then seeing if anything changes \n", instance.Status)
 			time.Sleep(time.Second * 5)
-			instance, err = gcpComputeService.Instances.Get(gcpProjectId, gcpZone, gcpServerName).Do()
+			instance, err = getInstance()
 			if err != nil {
-				log.Info("Cannot get instance details. ", err)
-				return false, "failed"
+				return 0, err
 			}
-		case "SUSPENDED", "SUSPENDING":
-			log.Info("Instance is in suspended (sleep) status: %v.\n", instance.Status)
-			return false, "server is suspended <&776313105788829727>"
+		case "SUSPENDED":
+			log.Infof("Instance is in suspended (sleep) status: %v.\n", instance.Status)
+			return 0, fmt.Errorf("%w: status %v", ErrSuspended, instance.Status)
 		}
 	}
 }
 
-func Whitelist(user string) (bool, string) {
-	if managementServerConnection == nil || managementServerConnection.GetState() != connectivity.Ready {
-		err := initiateConnectionToManagementServer()
-		if err != nil {
-			log.Infof("Unable to connect to management server: %v", err)
-			return false, "unable to connect to management server"
+// Suspends the instance, keeping its RAM state so that BringUpServer can
+// resume it quickly.
+func SuspendServer() (SuspendResult, error) {
+	instance, err := getInstance()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		switch instance.Status {
+		case "RUNNING":
+			log.Info("Instance was running, trying to suspend it now. ")
+
+			expectStop()
+			management.Close()
+
+			ops, err := gcpComputeService.Instances.Suspend(gcpProjectId, gcpZone, gcpServerName).Do()
+			if err != nil {
+				return 0, fmt.Errorf("call to suspend the instance failed: %w", err)
+			}
+			err = waitForOperation(ops)
+			if err != nil {
+				return 0, fmt.Errorf("cannot suspend GCP instance: %w", err)
+			}
+			log.Info("Instance suspended!")
+			return SuspendSuspended, nil
+		case "SUSPENDED":
+			log.Info("Instance was already suspended, doing nothing. ")
+			return SuspendAlreadySuspended, nil
+		case "STOPPED", "TERMINATED":
+			return 0, fmt.Errorf("%w: status %v", ErrNotRunning, instance.Status)
+		case "PROVISIONING", "DEPROVISIONING", "REPAIRING", "STAGING", "STOPPING", "SUSPENDING":
+			log.Infof("Instance is in transitional status: %v, waiting 5 seconds and then seeing if anything changes \n", instance.Status)
+			time.Sleep(time.Second * 5)
+			instance, err = getInstance()
+			if err != nil {
+				return 0, err
+			}
 		}
 	}
+}
 
+// Adds a player to the MC server whitelist. A rejection from the management
+// server is reported as a *WhitelistError. If the management server can't
+// be reached, RCON is tried instead.
+func Whitelist(user string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	r, err := managementServerClient.UpdateWhitelist(ctx, &pb.UpdateWhitelistRequest{
+	client, err := management.Client(ctx)
+	if err != nil {
+		return withRCONFallback(err, func() error { return rconWhitelist(user) })
+	}
+
+	r, err := client.UpdateWhitelist(ctx, &pb.UpdateWhitelistRequest{
 		Action:     pb.UpdateWhitelistRequest_ADD,
 		PlayerName: user,
 	})
 	if err != nil {
-		log.Infof("could not whitelist %v: %v", user, err)
-		return false, fmt.Sprintf("whitelist operation failed: %v", err)
+		return fmt.Errorf("could not whitelist %v: %w", user, err)
 	}
 	if r.ResultCode != pb.UpdateWhitelistResponse_ADD_OK {
-		log.Infof("could not whitelist %v: %v", user, r.Response)
-		return false, fmt.Sprintf("whitelist operation failed: %v", r.Response)
+		return &WhitelistError{Code: r.ResultCode, Response: r.Response}
+	}
+	return nil
+}
+
+// Fetches the number of players online from the management server, or
+// over RCON if the management server can't be reached.
+func PlayerCount() (uint32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := management.Client(ctx)
+	if err != nil {
+		var players []string
+		err = withRCONFallback(err, func() (rconErr error) {
+			players, rconErr = rconPlayers()
+			return rconErr
+		})
+		if err != nil {
+			return 0, err
+		}
+		metrics.PlayerCount.Set(float64(len(players)))
+		return uint32(len(players)), nil
 	}
-	return true, "done!"
+
+	r, err := client.GetPlayerCount(ctx, &pb.GetPlayerCountRequest{})
+	if err != nil {
+		return 0, fmt.Errorf("could not get player count: %w", err)
+	}
+	metrics.PlayerCount.Set(float64(r.Response.GetPlayerCount()))
+	return r.Response.GetPlayerCount(), nil
+}
+
+// Sends a message to every player on the MC server.
+func Broadcast(message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := management.Client(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.BroadcastMessage(ctx, &pb.BroadcastMessageRequest{Message: message})
+	if err != nil {
+		return fmt.Errorf("could not broadcast message: %w", err)
+	}
+	return nil
+}
+
+// Runs a command on the MC server's console and returns its output, over
+// RCON if the management server can't be reached or doesn't support it.
+func RunCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := management.Client(ctx)
+	if err == nil {
+		var r *pb.RunCommandResponse
+		r, err = client.RunCommand(ctx, &pb.RunCommandRequest{Command: command})
+		if err == nil {
+			return r.Output, nil
+		}
+		if status.Code(err) != codes.Unimplemented {
+			return "", fmt.Errorf("could not run command: %w", err)
+		}
+	}
+
+	var out string
+	err = withRCONFallback(err, func() (rconErr error) {
+		out, rconErr = rconCommand(command)
+		return rconErr
+	})
+	return out, err
 }
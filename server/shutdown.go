@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pb "github.com/mirrorkeydev/discord-mc-bot/proto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	defaultMinecraftStopTimeout = 2 * time.Minute
+	// How often the management server is asked to send a heartbeat while
+	// waiting for the MC server to exit.
+	stopHeartbeatInterval = 2 * time.Second
+)
+
+// How long the MC server gets to save the world and exit before the
+// instance is stopped regardless.
+var minecraftStopTimeout time.Duration
+
+// Set up the shutdown timeout, loading from environment where necessary
+func init() {
+	minecraftStopTimeout = defaultMinecraftStopTimeout
+	if seconds := envInt("MC_STOP_TIMEOUT_SECONDS"); seconds > 0 {
+		minecraftStopTimeout = time.Duration(seconds) * time.Second
+	}
+}
+
+// Asks the MC server to save the world and exit, then waits until the
+// management server's heartbeat reports that it has stopped. RCON is used
+// if the management server can't be reached.
+func stopMinecraft(progress func(Phase)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), minecraftStopTimeout)
+	defer cancel()
+
+	client, err := management.Client(ctx)
+	if err != nil {
+		return withRCONFallback(err, func() error {
+			progress(PhaseSaving)
+			return rconStop(ctx)
+		})
+	}
+
+	// Subscribe before asking for the stop so the SIGNAL_STOP can't be missed.
+	heartbeats, err := client.SubscribeHeartbeat(ctx, &pb.SubscribeHeartbeatRequest{
+		HeartbeatDurationSecAtleast: durationpb.New(stopHeartbeatInterval),
+	})
+	if err != nil {
+		return fmt.Errorf("could not subscribe to heartbeats: %w", err)
+	}
+
+	progress(PhaseSaving)
+	r, err := client.StopServer(ctx, &pb.StopServerRequest{SaveWorld: true})
+	if err != nil {
+		return fmt.Errorf("could not ask the MC server to stop: %w", err)
+	}
+	if !r.Accepted {
+		return fmt.Errorf("MC server refused to stop: %v", r.Response)
+	}
+
+	progress(PhaseWaitingForMinecraft)
+	for {
+		h, err := heartbeats.Recv()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("MC server did not stop within %v", minecraftStopTimeout)
+		}
+		if err != nil {
+			return fmt.Errorf("lost heartbeat while waiting for the MC server to stop: %w", err)
+		}
+		switch h.Status {
+		case pb.SubscribeHeartbeatResponse_SIGNAL_STOP:
+			log.Info("MC server saved the world and stopped. ")
+			return nil
+		case pb.SubscribeHeartbeatResponse_FATAL_STOP:
+			return errors.New("MC server crashed while stopping")
+		}
+	}
+}
@@ -1,26 +1,30 @@
 package server
 
 import (
-	"crypto/tls"
-	"crypto/x509"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"strings"
 	"time"
 
-	pb "github.com/mirrorkeydev/discord-mc-bot/proto"
+	"github.com/mirrorkeydev/discord-mc-bot/metrics"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/api/compute/v1"
 	"google.golang.org/api/googleapi"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
 )
 
-// Waits for a GCP compute operation to complete.
+// Waits for a GCP compute operation, zonal or global, to complete.
 // Referenced from https://github.com/googleapis/google-cloud-go/issues/178#issuecomment-489024603
-func waitForOperation(op *compute.Operation) error {
+func waitForOperation(op *compute.Operation) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveGCPOperation(op.OperationType, start, err) }()
+
 	for {
-		result, err := gcpComputeService.ZoneOperations.Get(gcpProjectId, gcpZone, op.Name).Do()
+		var result *compute.Operation
+		if op.Zone == "" {
+			result, err = gcpComputeService.GlobalOperations.Get(gcpProjectId, op.Name).Do()
+		} else {
+			result, err = gcpComputeService.ZoneOperations.Get(gcpProjectId, gcpZone, op.Name).Do()
+		}
 		if err != nil {
 			return fmt.Errorf("failed retriving operation status: %s", err)
 		}
@@ -37,25 +41,36 @@ func waitForOperation(op *compute.Operation) error {
 		}
 		time.Sleep(time.Second)
 	}
+	markGCPSuccess()
 	return nil
 }
 
-// Checks if the MC server is currently up, as reported by GCP compute.
-func IsUp() (bool, error) {
+// Fetches the MC server instance from GCP compute. A missing instance is
+// reported as ErrNotFound.
+func getInstance() (*compute.Instance, error) {
 	instance, err := gcpComputeService.Instances.Get(gcpProjectId, gcpZone, gcpServerName).Do()
-
 	if err != nil {
-		if e, ok := err.(*googleapi.Error); ok {
-			if e.Code == 404 {
-				return false, nil
-			} else {
-				log.WithError(err).Error("cannot get available instances")
-				return false, err
-			}
-		} else {
-			log.WithError(err).Error("cannot get available instances")
-			return false, err
+		if e, ok := err.(*googleapi.Error); ok && e.Code == 404 {
+			markGCPSuccess()
+			metrics.SetVMStatus("NOT_FOUND")
+			return nil, ErrNotFound
 		}
+		return nil, fmt.Errorf("cannot get instance details: %w", err)
+	}
+	markGCPSuccess()
+	metrics.SetVMStatus(instance.Status)
+	return instance, nil
+}
+
+// Checks if the MC server is currently up, as reported by GCP compute.
+func IsUp() (bool, error) {
+	instance, err := getInstance()
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		log.WithError(err).Error("cannot get available instances")
+		return false, err
 	}
 
 	switch instance.Status {
@@ -66,45 +81,12 @@ func IsUp() (bool, error) {
 	}
 }
 
-// This connection will fail if the management server (which is hosted on the
-// same instance as the MC server) isn't up yet. Therefore, this should only
-// be called after somebody manually tells the bot to bring the server up.
-func initiateConnectionToManagementServer() error {
-	certificate, err := tls.LoadX509KeyPair(
-		"certs/discord-mc-client.crt",
-		"certs/discord-mc-client.key",
-	)
+// Returns the instance's status as reported by GCP compute, e.g. RUNNING or
+// SUSPENDED. A missing instance is reported as ErrNotFound.
+func InstanceStatus() (string, error) {
+	instance, err := getInstance()
 	if err != nil {
-		log.WithError(err).Error("failed to read ca cert files")
-		return err
-	}
-
-	certPool := x509.NewCertPool()
-	bs, err := ioutil.ReadFile("certs/discord-mc.crt")
-	if err != nil {
-		log.WithError(err).Error("failed to read ca cert")
-		return err
-	}
-
-	ok := certPool.AppendCertsFromPEM(bs)
-	if !ok {
-		log.WithError(err).Error("failed to append certs")
-		return err
+		return "", err
 	}
-
-	transportCreds := credentials.NewTLS(&tls.Config{
-		ServerName:   ManagementServerAddress,
-		Certificates: []tls.Certificate{certificate},
-		RootCAs:      certPool,
-	})
-
-	managementServerConnection, err = grpc.Dial(fmt.Sprintf("%v:%v", ManagementServerAddress, managementServerPort), grpc.WithBlock(), grpc.WithTransportCredentials(transportCreds))
-	if err != nil {
-		log.WithError(err).Error("did not connect: %v /n")
-		return err
-	}
-
-	managementServerClient = pb.NewMCManagementClient(managementServerConnection)
-	log.WithError(err).Error("Connected to MC management server!")
-	return nil
+	return instance.Status, nil
 }
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// A Discord user's Minecraft account, keyed by Discord user ID.
+type Account struct {
+	MinecraftName string    `json:"minecraft_name"`
+	Linked        time.Time `json:"linked"`
+}
+
+func LinkAccount(s Store, userID string, a Account) error {
+	return s.Put(Accounts, userID, a)
+}
+
+// Returns the Minecraft name linked to a Discord user, or "" if they
+// haven't linked one.
+func MinecraftName(s Store, userID string) (string, error) {
+	var a Account
+	err := s.Get(Accounts, userID, &a)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	return a.MinecraftName, err
+}
+
+// Returns the Discord user linked to a Minecraft name, or "" if nobody
+// has linked it. Minecraft names are case insensitive.
+func DiscordUser(s Store, minecraftName string) (string, error) {
+	var userID string
+	err := s.List(Accounts, func(key string, value []byte) error {
+		var a Account
+		err := json.Unmarshal(value, &a)
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(a.MinecraftName, minecraftName) {
+			userID = key
+		}
+		return nil
+	})
+	return userID, err
+}
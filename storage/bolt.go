@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+var metaBucket = []byte("meta")
+var schemaVersionKey = []byte("schema_version")
+
+// BoltStore keeps state in an embedded BoltDB file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// Opens (creating if necessary) the database at path and applies any
+// pending migrations.
+func Open(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open database %v: %w", path, err)
+	}
+
+	err = migrate(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func migrate(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		version := 0
+		if v := meta.Get(schemaVersionKey); v != nil {
+			version = int(binary.BigEndian.Uint64(v))
+		}
+
+		for ; version < len(migrations); version++ {
+			log.Infof("Applying storage migration %v", version+1)
+			err := migrations[version](tx)
+			if err != nil {
+				return fmt.Errorf("migration %v failed: %w", version+1, err)
+			}
+		}
+
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, uint64(version))
+		return meta.Put(schemaVersionKey, v)
+	})
+}
+
+func bucket(tx *bolt.Tx, collection string) (*bolt.Bucket, error) {
+	b := tx.Bucket([]byte(collection))
+	if b == nil {
+		return nil, fmt.Errorf("unknown collection %v", collection)
+	}
+	return b, nil
+}
+
+func (s *BoltStore) Get(collection, key string, value interface{}) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, collection)
+		if err != nil {
+			return err
+		}
+		v := b.Get([]byte(key))
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, value)
+	})
+}
+
+func (s *BoltStore) Put(collection, key string, value interface{}) error {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, collection)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), v)
+	})
+}
+
+func (s *BoltStore) Delete(collection, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, collection)
+		if err != nil {
+			return err
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+func (s *BoltStore) List(collection string, fn func(key string, value []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, collection)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return fn(string(k), v)
+		})
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// A player dying in game.
+type Death struct {
+	Time   time.Time `json:"time"`
+	Player string    `json:"player"`
+	// The death message as shown in game, e.g. "Steve was slain by Zombie".
+	Message string `json:"message"`
+	// What killed the player, e.g. "was slain by Zombie".
+	Cause string `json:"cause"`
+}
+
+func AddDeath(s Store, d Death) error {
+	return s.Put(Deaths, TimeKey(d.Time)+"/"+d.Player, d)
+}
+
+// Returns the deaths since the given time, oldest first. The zero time
+// returns all of them.
+func DeathsSince(s Store, since time.Time) ([]Death, error) {
+	var deaths []Death
+	from := TimeKey(since)
+	err := s.List(Deaths, func(key string, value []byte) error {
+		if !since.IsZero() && key < from {
+			return nil
+		}
+		var d Death
+		err := json.Unmarshal(value, &d)
+		if err != nil {
+			return err
+		}
+		deaths = append(deaths, d)
+		return nil
+	})
+	return deaths, err
+}
+
+// Returns when the named report was last posted, or the zero time if it
+// never was.
+func LastReport(s Store, name string) (time.Time, error) {
+	var t time.Time
+	err := s.Get(Reports, name, &t)
+	if errors.Is(err, ErrNotFound) {
+		return time.Time{}, nil
+	}
+	return t, err
+}
+
+func SetLastReport(s Store, name string, t time.Time) error {
+	return s.Put(Reports, name, t)
+}
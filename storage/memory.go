@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MemoryStore keeps state in memory only. It behaves like BoltStore and is
+// meant for tests and for running the bot without a database.
+type MemoryStore struct {
+	mutex sync.RWMutex
+	data  map[string]map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{data: make(map[string]map[string][]byte)}
+	for _, c := range collections() {
+		s.data[c] = make(map[string][]byte)
+	}
+	return s
+}
+
+func (s *MemoryStore) collection(name string) (map[string][]byte, error) {
+	c, ok := s.data[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown collection %v", name)
+	}
+	return c, nil
+}
+
+func (s *MemoryStore) Get(collection, key string, value interface{}) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+	v, ok := c[key]
+	if !ok {
+		return ErrNotFound
+	}
+	return json.Unmarshal(v, value)
+}
+
+func (s *MemoryStore) Put(collection, key string, value interface{}) error {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+	c[key] = v
+	return nil
+}
+
+func (s *MemoryStore) Delete(collection, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+	delete(c, key)
+	return nil
+}
+
+func (s *MemoryStore) List(collection string, fn func(key string, value []byte) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		err := fn(k, c[k])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	bolt "go.etcd.io/bbolt"
+)
+
+// Each migration moves the schema up one version. Migrations are only ever
+// appended to; the schema version stored in the database is the number of
+// migrations that have been applied.
+var migrations = []func(tx *bolt.Tx) error{
+	// 1: uptime history
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(Uptime))
+		return err
+	},
+	// 2: Discord to Minecraft account links
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(Accounts))
+		return err
+	},
+	// 3: player sessions
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(Sessions))
+		return err
+	},
+	// 4: player deaths and when periodic reports were last posted
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(Deaths))
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte(Reports))
+		return err
+	},
+	// 5: shame ledger
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(Shames))
+		return err
+	},
+}
+
+// Collections created by the migrations, so the in-memory store can
+// mirror them.
+func collections() []string {
+	return []string{
+		Uptime,
+		Accounts,
+		Sessions,
+		Deaths,
+		Reports,
+		Shames,
+	}
+}
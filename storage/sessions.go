@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// A stretch of time a player spent on the MC server.
+type Session struct {
+	Player string    `json:"player"`
+	Start  time.Time `json:"start"`
+	// Zero while the player is still online.
+	End time.Time `json:"end"`
+	// Refreshed while the session is open, so that it can be closed at
+	// roughly the right time if the bot goes away before seeing the end.
+	LastSeen time.Time `json:"last_seen"`
+}
+
+func (s Session) Open() bool {
+	return s.End.IsZero()
+}
+
+// Adds a session, or updates it if it was added before.
+func PutSession(s Store, sess Session) error {
+	return s.Put(Sessions, TimeKey(sess.Start)+"/"+sess.Player, sess)
+}
+
+// Returns the sessions that haven't ended.
+func OpenSessions(s Store) ([]Session, error) {
+	var open []Session
+	err := listSessions(s, func(sess Session) {
+		if sess.Open() {
+			open = append(open, sess)
+		}
+	})
+	return open, err
+}
+
+// Returns how long each player has played since the given time, counting
+// open sessions up to now. The zero time counts all sessions.
+func Playtime(s Store, since time.Time) (map[string]time.Duration, error) {
+	now := time.Now()
+	totals := make(map[string]time.Duration)
+	err := listSessions(s, func(sess Session) {
+		start, end := sess.Start, sess.End
+		if sess.Open() {
+			end = now
+		}
+		if start.Before(since) {
+			start = since
+		}
+		if end.After(start) {
+			totals[sess.Player] += end.Sub(start)
+		}
+	})
+	return totals, err
+}
+
+func listSessions(s Store, fn func(Session)) error {
+	return s.List(Sessions, func(key string, value []byte) error {
+		var sess Session
+		err := json.Unmarshal(value, &sess)
+		if err != nil {
+			return err
+		}
+		fn(sess)
+		return nil
+	})
+}
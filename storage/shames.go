@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// One user shaming another.
+type Shame struct {
+	Time     time.Time `json:"time"`
+	ShamerID string    `json:"shamer_id"`
+	TargetID string    `json:"target_id"`
+	Reason   string    `json:"reason"`
+}
+
+func AddShame(s Store, sh Shame) error {
+	return s.Put(Shames, TimeKey(sh.Time)+"/"+sh.ShamerID, sh)
+}
+
+// Returns every shame, oldest first.
+func AllShames(s Store) ([]Shame, error) {
+	var shames []Shame
+	err := s.List(Shames, func(key string, value []byte) error {
+		var sh Shame
+		err := json.Unmarshal(value, &sh)
+		if err != nil {
+			return err
+		}
+		shames = append(shames, sh)
+		return nil
+	})
+	return shames, err
+}
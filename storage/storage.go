@@ -0,0 +1,40 @@
+// Persistent storage for bot state that must survive restarts. Records are
+// stored as JSON in named collections, ordered by key.
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned when a key does not exist in a collection.
+var ErrNotFound = errors.New("record not found")
+
+// Collections available in every store.
+const (
+	Uptime   = "uptime"
+	Accounts = "accounts"
+	Sessions = "sessions"
+	Deaths   = "deaths"
+	Reports  = "reports"
+	Shames   = "shames"
+)
+
+// Store is the repository interface used by the rest of the bot. Values
+// are marshalled to JSON; List visits keys in ascending order.
+type Store interface {
+	Get(collection, key string, value interface{}) error
+	Put(collection, key string, value interface{}) error
+	Delete(collection, key string) error
+	List(collection string, fn func(key string, value []byte) error) error
+	Close() error
+}
+
+// Returns a key that sorts in time order, for collections of events. Only
+// times between 1970 and 2262 get keys that sort correctly, so the zero
+// time has no meaningful key; range queries treat it as no lower bound.
+func TimeKey(t time.Time) string {
+	return fmt.Sprintf("%020d", t.UnixNano())
+}
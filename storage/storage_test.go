@@ -0,0 +1,412 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Runs test against a fresh BoltStore and a fresh MemoryStore, so both
+// implementations are held to the same behaviour.
+func forEachStore(t *testing.T, test func(t *testing.T, s Store)) {
+	t.Run("bolt", func(t *testing.T) {
+		s, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		test(t, s)
+	})
+	t.Run("memory", func(t *testing.T) {
+		s := NewMemoryStore()
+		defer s.Close()
+		test(t, s)
+	})
+}
+
+func schemaVersion(t *testing.T, path string) int {
+	t.Helper()
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	version := -1
+	err = db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if meta == nil {
+			return errors.New("no meta bucket")
+		}
+		version = int(binary.BigEndian.Uint64(meta.Get(schemaVersionKey)))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name string
+		// Migrations already applied before Open is called; -1 for a new
+		// database.
+		applied int
+	}{
+		{name: "new database", applied: -1},
+		{name: "first version", applied: 1},
+		{name: "part way", applied: len(migrations) - 1},
+		{name: "up to date", applied: len(migrations)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "bot.db")
+			if test.applied >= 0 {
+				db, err := bolt.Open(path, 0600, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = db.Update(func(tx *bolt.Tx) error {
+					for _, m := range migrations[:test.applied] {
+						if err := m(tx); err != nil {
+							return err
+						}
+					}
+					// Data written by an older version must survive.
+					if err := tx.Bucket([]byte(Uptime)).Put([]byte("old"), []byte(`{"status":"up"}`)); err != nil {
+						return err
+					}
+					meta, err := tx.CreateBucket(metaBucket)
+					if err != nil {
+						return err
+					}
+					v := make([]byte, 8)
+					binary.BigEndian.PutUint64(v, uint64(test.applied))
+					return meta.Put(schemaVersionKey, v)
+				})
+				db.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Opening twice re-runs migrate on an up to date database.
+			for n := 0; n < 2; n++ {
+				s, err := Open(path)
+				if err != nil {
+					t.Fatalf("open %v: %v", n+1, err)
+				}
+				for _, c := range collections() {
+					err := s.List(c, func(string, []byte) error { return nil })
+					if err != nil {
+						t.Errorf("open %v: collection %v: %v", n+1, c, err)
+					}
+				}
+				if test.applied >= 0 {
+					var e UptimeEvent
+					err := s.Get(Uptime, "old", &e)
+					if err != nil || e.Status != "up" {
+						t.Errorf("open %v: old record = %+v, %v", n+1, e, err)
+					}
+				}
+				s.Close()
+
+				if got := schemaVersion(t, path); got != len(migrations) {
+					t.Errorf("open %v: schema version = %v, want %v", n+1, got, len(migrations))
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreMatchesMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buckets []string
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if string(name) != string(metaBucket) {
+				buckets = append(buckets, string(name))
+			}
+			return nil
+		})
+	})
+	s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := make(map[string]bool)
+	for _, c := range collections() {
+		want[c] = true
+	}
+	if len(buckets) != len(want) {
+		t.Errorf("migrations create %v, collections() lists %v", buckets, collections())
+	}
+	for _, b := range buckets {
+		if !want[b] {
+			t.Errorf("bucket %v is missing from collections()", b)
+		}
+	}
+}
+
+type record struct {
+	Name string `json:"name"`
+}
+
+func listKeys(t *testing.T, s Store, collection string) []string {
+	t.Helper()
+	var keys []string
+	err := s.List(collection, func(key string, value []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		var r record
+		if err := s.Get(Accounts, "missing", &r); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get of a missing key = %v, want ErrNotFound", err)
+		}
+		if err := s.Get("nope", "key", &r); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Get from an unknown collection = %v, want an error", err)
+		}
+		if err := s.Put("nope", "key", record{}); err == nil {
+			t.Error("Put to an unknown collection succeeded")
+		}
+		if err := s.List("nope", func(string, []byte) error { return nil }); err == nil {
+			t.Error("List of an unknown collection succeeded")
+		}
+
+		for _, key := range []string{"b", "c", "a"} {
+			if err := s.Put(Accounts, key, record{Name: key}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.Put(Accounts, "b", record{Name: "b2"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Get(Accounts, "b", &r); err != nil || r.Name != "b2" {
+			t.Errorf("Get after overwrite = %+v, %v", r, err)
+		}
+		if got, want := listKeys(t, s, Accounts), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("List = %v, want %v", got, want)
+		}
+		if got := listKeys(t, s, Sessions); len(got) != 0 {
+			t.Errorf("other collections must be unaffected, got %v", got)
+		}
+
+		if err := s.Delete(Accounts, "b"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Delete(Accounts, "missing"); err != nil {
+			t.Errorf("Delete of a missing key = %v", err)
+		}
+		if got, want := listKeys(t, s, Accounts), []string{"a", "c"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("List after Delete = %v, want %v", got, want)
+		}
+
+		stop := errors.New("stop")
+		var visited int
+		err := s.List(Accounts, func(string, []byte) error {
+			visited++
+			return stop
+		})
+		if !errors.Is(err, stop) || visited != 1 {
+			t.Errorf("List must stop at the first error, got %v after %v keys", err, visited)
+		}
+	})
+}
+
+func TestTimeKeyOrder(t *testing.T) {
+	base := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	times := []time.Time{
+		base,
+		base.Add(time.Nanosecond),
+		base.Add(time.Hour),
+		base.AddDate(10, 0, 0),
+		time.Unix(0, 0),
+	}
+	for _, a := range times {
+		for _, b := range times {
+			if (TimeKey(a) < TimeKey(b)) != a.Before(b) {
+				t.Errorf("TimeKey(%v) < TimeKey(%v) disagrees with time order", a, b)
+			}
+		}
+	}
+}
+
+func TestDeathsSince(t *testing.T) {
+	base := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	deaths := []Death{
+		// TimeKey can't order this against the zero time, so it's only
+		// found if all time really means no lower bound.
+		{Time: time.Date(1969, 7, 20, 20, 17, 0, 0, time.UTC), Player: "Neil"},
+		{Time: base.Add(-time.Hour), Player: "Steve"},
+		{Time: base, Player: "Alex"},
+		{Time: base, Player: "Steve"},
+		{Time: base.Add(time.Nanosecond), Player: "Alex"},
+		{Time: base.Add(24 * time.Hour), Player: "Steve"},
+	}
+
+	tests := []struct {
+		name  string
+		since time.Time
+		want  int
+	}{
+		{name: "all time", since: time.Time{}, want: 6},
+		{name: "before everything", since: base.Add(-2 * time.Hour), want: 5},
+		{name: "inclusive", since: base, want: 4},
+		{name: "just after", since: base.Add(time.Nanosecond), want: 2},
+		{name: "after everything", since: base.Add(48 * time.Hour), want: 0},
+	}
+
+	forEachStore(t, func(t *testing.T, s Store) {
+		for _, d := range deaths {
+			if err := AddDeath(s, d); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, test := range tests {
+			got, err := DeathsSince(s, test.since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != test.want {
+				t.Errorf("%v: got %v deaths, want %v", test.name, len(got), test.want)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].Time.Before(got[i-1].Time) {
+					t.Errorf("%v: deaths out of order: %v", test.name, got)
+				}
+			}
+		}
+	})
+}
+
+func TestUptimeHistory(t *testing.T) {
+	base := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	forEachStore(t, func(t *testing.T, s Store) {
+		for i, status := range []string{"up", "down", "up"} {
+			err := AddUptimeEvent(s, UptimeEvent{Time: base.Add(time.Duration(i) * time.Hour), Status: status})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Before anything TimeKey can order, see TestDeathsSince.
+		err := AddUptimeEvent(s, UptimeEvent{Time: time.Date(1969, 1, 1, 0, 0, 0, 0, time.UTC), Status: "created"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			since time.Time
+			want  []string
+		}{
+			{since: time.Time{}, want: []string{"created", "up", "down", "up"}},
+			{since: base.Add(time.Hour), want: []string{"down", "up"}},
+			{since: base.Add(time.Hour + 1), want: []string{"up"}},
+			{since: base.Add(3 * time.Hour), want: nil},
+		}
+		for _, test := range tests {
+			events, err := UptimeHistory(s, test.since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range events {
+				got = append(got, e.Status)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("since %v: got %v, want %v", test.since, got, test.want)
+			}
+		}
+	})
+}
+
+func TestPlaytime(t *testing.T) {
+	now := time.Now()
+	sessions := []Session{
+		// Ended a day ago, an hour long.
+		{Player: "Steve", Start: now.Add(-25 * time.Hour), End: now.Add(-24 * time.Hour)},
+		// Straddles the two hour mark.
+		{Player: "Steve", Start: now.Add(-3 * time.Hour), End: now.Add(-time.Hour)},
+		// Still open.
+		{Player: "Alex", Start: now.Add(-30 * time.Minute)},
+	}
+
+	tests := []struct {
+		name  string
+		since time.Time
+		want  map[string]time.Duration
+	}{
+		{
+			name:  "all time",
+			since: time.Time{},
+			want:  map[string]time.Duration{"Steve": 3 * time.Hour, "Alex": 30 * time.Minute},
+		},
+		{
+			name:  "clipped to since",
+			since: now.Add(-2 * time.Hour),
+			want:  map[string]time.Duration{"Steve": time.Hour, "Alex": 30 * time.Minute},
+		},
+		{
+			name:  "only the open session",
+			since: now.Add(-time.Hour),
+			want:  map[string]time.Duration{"Alex": 30 * time.Minute},
+		},
+	}
+
+	forEachStore(t, func(t *testing.T, s Store) {
+		for _, sess := range sessions {
+			if err := PutSession(s, sess); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Updating a session replaces it rather than adding another.
+		sessions[2].LastSeen = now
+		if err := PutSession(s, sessions[2]); err != nil {
+			t.Fatal(err)
+		}
+		open, err := OpenSessions(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(open) != 1 || open[0].Player != "Alex" {
+			t.Errorf("OpenSessions = %+v", open)
+		}
+
+		for _, test := range tests {
+			got, err := Playtime(s, test.since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(test.want) {
+				t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+				continue
+			}
+			for player, want := range test.want {
+				// Open sessions run up to the time Playtime is called.
+				if d := got[player] - want; d < 0 || d > time.Minute {
+					t.Errorf("%v: %v played %v, want %v", test.name, player, got[player], want)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// A change in the MC server's state made through the bot.
+type UptimeEvent struct {
+	Time   time.Time `json:"time"`
+	Status string    `json:"status"`
+	UserID string    `json:"user_id,omitempty"`
+}
+
+func AddUptimeEvent(s Store, e UptimeEvent) error {
+	return s.Put(Uptime, TimeKey(e.Time), e)
+}
+
+// Returns the uptime events recorded since the given time, oldest first.
+// The zero time returns all of them.
+func UptimeHistory(s Store, since time.Time) ([]UptimeEvent, error) {
+	var events []UptimeEvent
+	from := TimeKey(since)
+	err := s.List(Uptime, func(key string, value []byte) error {
+		if !since.IsZero() && key < from {
+			return nil
+		}
+		var e UptimeEvent
+		err := json.Unmarshal(value, &e)
+		if err != nil {
+			return err
+		}
+		events = append(events, e)
+		return nil
+	})
+	return events, err
+}